@@ -0,0 +1,64 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package cmd
+
+import (
+	"log"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/talos-systems/bldr/internal/pkg/solver"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+type catalogEntry struct {
+	Name string `yaml:"name"`
+
+	v1alpha2.Metadata `yaml:",inline"`
+}
+
+// catalogCmd represents the catalog command.
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "List packages with their metadata",
+	Long: `This command scans directory tree for pkg.yaml files,
+and outputs YAML list of packages with their metadata (version, license, etc.).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		loader := solver.FilesystemPackageLoader{
+			Root:    pkgRoot,
+			Context: options.GetVariables(),
+		}
+
+		packages, err := solver.NewPackages(&loader)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		set := packages.ToSet()
+		catalog := make([]catalogEntry, 0, len(set))
+
+		for _, node := range set {
+			catalog = append(catalog, catalogEntry{
+				Name:     node.Name,
+				Metadata: node.Pkg.Metadata,
+			})
+		}
+
+		sort.Slice(catalog, func(i, j int) bool {
+			return catalog[i].Name < catalog[j].Name
+		})
+
+		if err = yaml.NewEncoder(os.Stdout).Encode(catalog); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(catalogCmd)
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 
 	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/client/llb/imagemetaresolver"
 	solverpb "github.com/moby/buildkit/solver/pb"
 	"github.com/spf13/cobra"
 
@@ -45,6 +46,9 @@ and outputs buildkit LLB to stdout. This can be used as 'bldr pack ... | buildct
 			log.Fatal(err)
 		}
 
+		// image configs are fetched from the registries directly
+		options.MetaResolver = imagemetaresolver.Default()
+
 		dt, err := convert.MarshalLLB(graph, options)
 		if err != nil {
 			log.Fatal(err)
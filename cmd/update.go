@@ -79,6 +79,10 @@ func checkUpdates(ctx context.Context, set solver.PackageSet, l *log.Logger) err
 	for _, node := range set {
 		for _, step := range node.Pkg.Steps {
 			for _, src := range step.Sources {
+				if src.IsGit() || src.IsFile() {
+					continue
+				}
+
 				sources <- &pkgInfo{
 					file:   node.Pkg.FileName,
 					source: src.URL,
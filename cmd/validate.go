@@ -9,17 +9,20 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
 
+	"github.com/talos-systems/bldr/internal/pkg/constants"
 	"github.com/talos-systems/bldr/internal/pkg/solver"
 	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
 )
 
-func validateChecksums(ctx context.Context, set solver.PackageSet, l *log.Logger) error {
+func validateChecksums(ctx context.Context, set solver.PackageSet, mirrors v1alpha2.Mirrors, l *log.Logger) error {
 	var (
 		wg          sync.WaitGroup
 		concurrency = runtime.GOMAXPROCS(-1)
@@ -37,9 +40,25 @@ func validateChecksums(ctx context.Context, set solver.PackageSet, l *log.Logger
 			for pkg := range pkgs {
 				for _, step := range pkg.Steps {
 					for _, src := range step.Sources {
-						l.Printf("downloading %s ...", src.URL)
+						if src.IsGit() {
+							// git sources are pinned by commit hash
+							continue
+						}
+
+						var err error
+
+						if src.IsFile() {
+							path := filepath.Join(pkgRoot, src.ContextPath(pkg.BaseDir))
+
+							l.Printf("reading %s ...", path)
+
+							_, _, err = src.ValidateFileChecksums(path)
+						} else {
+							l.Printf("downloading %s ...", src.URL)
+
+							_, _, err = src.ValidateChecksums(ctx, mirrors)
+						}
 
-						_, _, err := src.ValidateChecksums(ctx)
 						if err != nil {
 							errors <- fmt.Errorf("%s: %w", pkg.Name, err)
 						}
@@ -76,6 +95,87 @@ func validateChecksums(ctx context.Context, set solver.PackageSet, l *log.Logger
 	return multiErr.ErrorOrNil()
 }
 
+// validatePatches checks that patch files exist, and that packages which use `patches:`
+// don't have unused patch files in the package directory.
+func validatePatches(set solver.PackageSet) error {
+	var multiErr *multierror.Error
+
+	for _, node := range set {
+		pkg := node.Pkg
+		used := map[string]struct{}{}
+
+		for _, step := range pkg.Steps {
+			for _, patch := range step.Patches {
+				path := filepath.Join(pkgRoot, patch.ContextPath(pkg.BaseDir))
+				used[path] = struct{}{}
+
+				if _, err := os.Stat(path); err != nil {
+					multiErr = multierror.Append(multiErr, fmt.Errorf("%s: patch %q: %w", pkg.Name, patch.File, err))
+				}
+			}
+		}
+
+		if len(used) == 0 {
+			continue
+		}
+
+		pkgDir := filepath.Join(pkgRoot, pkg.BaseDir)
+
+		err := filepath.Walk(pkgDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				if path == pkgDir {
+					return nil
+				}
+
+				if _, err = os.Stat(filepath.Join(path, constants.PkgYaml)); err == nil {
+					// nested package is validated on its own
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			switch filepath.Ext(path) {
+			case ".patch", ".diff":
+			default:
+				return nil
+			}
+
+			if _, ok := used[path]; !ok {
+				rel, _ := filepath.Rel(pkgDir, path) //nolint:errcheck
+
+				multiErr = multierror.Append(multiErr, fmt.Errorf("%s: patch %q is not used", pkg.Name, rel))
+			}
+
+			return nil
+		})
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// validateVariantKeys checks that repository signing keys of the variants exist.
+func validateVariantKeys(variants v1alpha2.Variants) error {
+	var multiErr *multierror.Error
+
+	for name, spec := range variants {
+		for _, key := range spec.Keys {
+			if _, err := os.Stat(filepath.Join(pkgRoot, key)); err != nil {
+				multiErr = multierror.Append(multiErr, fmt.Errorf("variant %q: key %q: %w", name, key, err))
+			}
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
 var validateCmdFlags struct {
 	checksums bool
 }
@@ -87,9 +187,11 @@ var validateCmd = &cobra.Command{
 	Long: `This command scans directory tree for pkg.yaml files,
 loads them and validates for errors. `,
 	Run: func(cmd *cobra.Command, args []string) {
+		// entries which are dropped by `when` conditions for the host platform are validated as well
 		loader := solver.FilesystemPackageLoader{
-			Root:    pkgRoot,
-			Context: options.GetVariables(),
+			Root:             pkgRoot,
+			Context:          options.GetVariables(),
+			IgnoreConditions: true,
 		}
 
 		packages, err := solver.NewPackages(&loader)
@@ -97,13 +199,21 @@ loads them and validates for errors. `,
 			log.Fatal(err)
 		}
 
+		if err = validatePatches(packages.ToSet()); err != nil {
+			log.Fatal(err)
+		}
+
+		if err = validateVariantKeys(packages.Variants()); err != nil {
+			log.Fatal(err)
+		}
+
 		if validateCmdFlags.checksums {
 			l := log.New(log.Writer(), "[validate] ", log.Flags())
 			if !debug {
 				l.SetOutput(ioutil.Discard)
 			}
 
-			if err = validateChecksums(context.TODO(), packages.ToSet(), l); err != nil {
+			if err = validateChecksums(context.TODO(), packages.ToSet(), packages.Mirrors(), l); err != nil {
 				log.Fatal(err)
 			}
 		}
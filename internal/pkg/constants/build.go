@@ -12,6 +12,9 @@ const DefaultBaseImage = "docker.io/alpine:3.14"
 // DefaultDirMode is UNIX file mode for mkdir.
 const DefaultDirMode os.FileMode = 0755
 
+// StepTmpDirTemplate is a template for the default step directory, formatted with step index.
+const StepTmpDirTemplate = "/tmp/build/%d"
+
 // DefaultPath is default value for PATH environment variable.
 const DefaultPath = "/bin:/usr/bin:/sbin:/usr/sbin"
 
@@ -20,3 +23,13 @@ const PkgYaml = "pkg.yaml"
 
 // Pkgfile is the filename of 'Pkgfile'.
 const Pkgfile = "Pkgfile"
+
+// Labels set by bldr on the output images.
+const (
+	// LabelName is the name of the package (or package output) the image was built from.
+	LabelName = "dev.talos-systems.bldr.name"
+	// LabelTo is the location package contents were finalized to.
+	LabelTo = "dev.talos-systems.bldr.to"
+	// LabelRuntimeDependencies is a comma-separated list of runtime dependencies of the package.
+	LabelRuntimeDependencies = "dev.talos-systems.bldr.runtime-dependencies"
+)
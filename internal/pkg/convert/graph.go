@@ -6,9 +6,14 @@ package convert
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"path"
 	"sort"
 
+	"github.com/docker/distribution/reference"
 	"github.com/moby/buildkit/client/llb"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/talos-systems/bldr/internal/pkg/constants"
 	"github.com/talos-systems/bldr/internal/pkg/environment"
 	"github.com/talos-systems/bldr/internal/pkg/solver"
@@ -23,12 +28,21 @@ type GraphLLB struct {
 
 	Options *environment.Options
 
+	Variants     v1alpha2.Variants
 	BaseImages   map[v1alpha2.Variant]llb.State
 	Checksummer  llb.State
+	Extractor    llb.State
+	Patcher      llb.State
+	GitTool      llb.State
+	Utils        llb.State
 	LocalContext llb.State
 
 	baseImageProcessor llbProcessor
-	cache              map[*solver.PackageNode]llb.State
+	cache              map[*solver.PackageNode]llb.State // build roots before finalize
+	imageLocations     map[string]string                 // image -> location from the bldr label
+
+	// platformGraphs is shared between all the graphs built for different build/target platform pairs.
+	platformGraphs map[string]*GraphLLB
 
 	commonRunOptions []llb.RunOption
 }
@@ -38,22 +52,71 @@ type llbProcessor func(llb.State) llb.State
 // NewGraphLLB creates new GraphLLB and initializes shared images.
 func NewGraphLLB(graph *solver.PackageGraph, options *environment.Options) *GraphLLB {
 	result := &GraphLLB{
-		PackageGraph: graph,
-		Options:      options,
-		cache:        make(map[*solver.PackageNode]llb.State),
+		PackageGraph:   graph,
+		Options:        options,
+		cache:          make(map[*solver.PackageNode]llb.State),
+		imageLocations: make(map[string]string),
+	}
+
+	result.platformGraphs = map[string]*GraphLLB{
+		platformKey(options): result,
 	}
 
 	if options.ProxyEnv != nil {
 		result.commonRunOptions = append(result.commonRunOptions, llb.WithProxy(*options.ProxyEnv))
 	}
 
+	result.buildLocalContext()
 	result.buildBaseImages()
 	result.buildChecksummer()
-	result.buildLocalContext()
+	result.buildExtractor()
+	result.buildPatcher()
+	result.buildGitTool()
+	result.buildUtils()
 
 	return result
 }
 
+func platformKey(options *environment.Options) string {
+	return options.BuildPlatform.ID + "," + options.TargetPlatform.ID
+}
+
+// forPlatform returns graph to build dependencies with the platform override.
+//
+// Packages are loaded again with the variables of the platform, so that templates
+// and conditions are evaluated for the overridden platform.
+func (graph *GraphLLB) forPlatform(platform v1alpha2.DependencyPlatform) (*GraphLLB, error) {
+	options := *graph.Options
+
+	switch platform {
+	case v1alpha2.PlatformBuild:
+		options.TargetPlatform = options.BuildPlatform
+	case v1alpha2.PlatformTarget:
+		options.BuildPlatform = options.TargetPlatform
+	case v1alpha2.PlatformDefault:
+		return graph, nil
+	}
+
+	key := platformKey(&options)
+
+	if platformGraph, ok := graph.platformGraphs[key]; ok {
+		return platformGraph, nil
+	}
+
+	packageGraph, err := graph.PackageGraph.ForContext(options.GetVariables())
+	if err != nil {
+		return nil, fmt.Errorf("error loading packages for %s: %w", options.TargetPlatform.ID, err)
+	}
+
+	options.CommonPrefix = fmt.Sprintf("%s%s ", graph.Options.CommonPrefix, options.TargetPlatform.ID)
+
+	platformGraph := NewGraphLLB(packageGraph, &options)
+	platformGraph.platformGraphs = graph.platformGraphs
+	graph.platformGraphs[key] = platformGraph
+
+	return platformGraph, nil
+}
+
 func (graph *GraphLLB) buildBaseImages() {
 	graph.BaseImages = make(map[v1alpha2.Variant]llb.State)
 
@@ -75,46 +138,132 @@ func (graph *GraphLLB) buildBaseImages() {
 		sort.Strings(keys)
 
 		for _, key := range keys {
-			root = root.AddEnv(key, vars[key])
+			// lists and maps are available only for templating
+			if value, ok := vars[key].(string); ok {
+				root = root.AddEnv(key, value)
+			}
 		}
 
 		return root
 	}
 
 	graph.baseImageProcessor = func(root llb.State) llb.State {
-		return addEnv(addPkg(root))
+		return addEnv(addPkg(root.Platform(graph.Options.BuildPlatform.PlatformSpec)))
+	}
+
+	graph.Variants = graph.Pkgfile.AllVariants()
+
+	for variant, spec := range graph.Variants {
+		graph.BaseImages[variant] = graph.baseImageProcessor(graph.buildBaseImage(variant, spec))
+	}
+}
+
+func (graph *GraphLLB) buildBaseImage(variant v1alpha2.Variant, spec v1alpha2.VariantSpec) llb.State {
+	if spec.IsScratch() {
+		return llb.Scratch()
+	}
+
+	prefix := fmt.Sprintf("%sbase-%s", graph.Options.CommonPrefix, variant)
+
+	root := llb.Image(
+		spec.Image,
+		llb.WithCustomName(prefix),
+		graph.Options.BuildPlatform.LLBPlatform,
+	).Platform(graph.Options.BuildPlatform.PlatformSpec)
+
+	for i, instruction := range spec.Setup {
+		root = root.Run(
+			append(graph.commonRunOptions,
+				llb.Args([]string{"/bin/sh", "-c", string(instruction)}),
+				llb.WithCustomNamef("%s-setup-%d", prefix, i),
+			)...,
+		).Root()
+	}
+
+	pm := packageManagers[spec.PackageManager]
+	if pm == nil {
+		return root
 	}
 
-	graph.BaseImages[v1alpha2.Alpine] = graph.baseImageProcessor(llb.Image(
+	keys := make([]string, 0, len(spec.Keys))
+
+	for _, key := range spec.Keys {
+		dest := path.Join(pm.keysDir, path.Base(key))
+		keys = append(keys, dest)
+
+		root = root.File(
+			llb.Copy(graph.LocalContext, path.Join("/", key), dest, &llb.CopyInfo{CreateDestPath: true}),
+			llb.WithCustomNamef("%s-key %s", prefix, key),
+		)
+	}
+
+	if len(spec.Repositories) > 0 {
+		root = root.Run(
+			append(graph.commonRunOptions,
+				llb.Args([]string{"/bin/sh", "-c", pm.repositoriesScript(spec.Repositories, keys)}),
+				llb.WithCustomNamef("%s-repositories", prefix),
+			)...,
+		).Root()
+	}
+
+	return root
+}
+
+func (graph *GraphLLB) buildChecksummer() {
+	graph.Checksummer = llb.Image(
 		constants.DefaultBaseImage,
-		llb.WithCustomName(graph.Options.CommonPrefix+"base"),
+		llb.WithCustomName(graph.Options.CommonPrefix+"cksum"),
 	).Run(
 		append(graph.commonRunOptions,
-			llb.Shlex("apk --no-cache --update add bash"),
-			llb.WithCustomName(graph.Options.CommonPrefix+"base-apkinstall"),
+			llb.Shlex("apk --no-cache --update add coreutils"),
+			llb.WithCustomName(graph.Options.CommonPrefix+"cksum-apkinstall"),
 		)...,
+	).Root()
+}
+
+func (graph *GraphLLB) buildExtractor() {
+	graph.Extractor = llb.Image(
+		constants.DefaultBaseImage,
+		llb.WithCustomName(graph.Options.CommonPrefix+"extract"),
 	).Run(
 		append(graph.commonRunOptions,
-			llb.Args([]string{"ln", "-svf", "/bin/bash", "/bin/sh"}),
-			llb.WithCustomName(graph.Options.CommonPrefix+"base-symlink"),
+			llb.Shlex("apk --no-cache --update add libarchive-tools xz zstd"),
+			llb.WithCustomName(graph.Options.CommonPrefix+"extract-apkinstall"),
 		)...,
-	).Root())
+	).Root()
+}
 
-	graph.BaseImages[v1alpha2.Scratch] = graph.baseImageProcessor(llb.Scratch())
+func (graph *GraphLLB) buildPatcher() {
+	graph.Patcher = llb.Image(
+		constants.DefaultBaseImage,
+		llb.WithCustomName(graph.Options.CommonPrefix+"patch"),
+	).Run(
+		append(graph.commonRunOptions,
+			llb.Shlex("apk --no-cache --update add patch"),
+			llb.WithCustomName(graph.Options.CommonPrefix+"patch-apkinstall"),
+		)...,
+	).Root()
 }
 
-func (graph *GraphLLB) buildChecksummer() {
-	graph.Checksummer = llb.Image(
+func (graph *GraphLLB) buildGitTool() {
+	graph.GitTool = llb.Image(
 		constants.DefaultBaseImage,
-		llb.WithCustomName(graph.Options.CommonPrefix+"cksum"),
+		llb.WithCustomName(graph.Options.CommonPrefix+"git"),
 	).Run(
 		append(graph.commonRunOptions,
-			llb.Shlex("apk --no-cache --update add coreutils"),
-			llb.WithCustomName(graph.Options.CommonPrefix+"cksum-apkinstall"),
+			llb.Shlex("apk --no-cache --update add git"),
+			llb.WithCustomName(graph.Options.CommonPrefix+"git-apkinstall"),
 		)...,
 	).Root()
 }
 
+func (graph *GraphLLB) buildUtils() {
+	graph.Utils = llb.Image(
+		constants.DefaultBaseImage,
+		llb.WithCustomName(graph.Options.CommonPrefix+"utils"),
+	)
+}
+
 func (graph *GraphLLB) buildLocalContext() {
 	graph.LocalContext = llb.Local(
 		"context",
@@ -128,9 +277,60 @@ func (graph *GraphLLB) buildLocalContext() {
 	)
 }
 
+// imageLocation returns the location image contents were finalized to by bldr.
+//
+// Empty location is returned if the image wasn't built by bldr or image configs can't be resolved.
+func (graph *GraphLLB) imageLocation(image string) (string, error) {
+	if location, ok := graph.imageLocations[image]; ok {
+		return location, nil
+	}
+
+	if graph.Options.MetaResolver == nil {
+		return "", nil
+	}
+
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("error parsing image reference %q: %w", image, err)
+	}
+
+	_, config, err := graph.Options.MetaResolver.ResolveImageConfig(context.TODO(), reference.TagNameOnly(ref).String(), llb.ResolveImageConfigOpt{
+		Platform: &graph.Options.BuildPlatform.PlatformSpec,
+		LogName:  fmt.Sprintf("%sresolve image config for %s", graph.Options.CommonPrefix, image),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error resolving image config for %q: %w", image, err)
+	}
+
+	var img specs.Image
+
+	if err = json.Unmarshal(config, &img); err != nil {
+		return "", fmt.Errorf("error parsing image config for %q: %w", image, err)
+	}
+
+	var location string
+
+	// images not built by bldr are not trusted to have the label
+	if _, ok := img.Config.Labels[constants.LabelName]; ok {
+		location = img.Config.Labels[constants.LabelTo]
+	}
+
+	graph.imageLocations[image] = location
+
+	return location, nil
+}
+
+func (graph *GraphLLB) mirrors() v1alpha2.Mirrors {
+	if graph.Pkgfile == nil {
+		return nil
+	}
+
+	return graph.Pkgfile.Mirrors
+}
+
 // Build converts package graph to LLB.
 func (graph *GraphLLB) Build() (llb.State, error) {
-	return NewNodeLLB(graph.Root, graph).Build()
+	return NewNodeLLB(graph.Root, graph).BuildOutput(graph.Output)
 }
 
 // Marshal returns marshaled LLB.
@@ -0,0 +1,239 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/constants"
+	"github.com/talos-systems/bldr/internal/pkg/environment"
+	"github.com/talos-systems/bldr/internal/pkg/solver"
+)
+
+// loadGraph loads the package tree from files and resolves the target.
+func loadGraph(t *testing.T, files map[string]string, target string, options *environment.Options) *solver.PackageGraph {
+	root := t.TempDir()
+
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0o644))
+	}
+
+	packages, err := solver.NewPackages(&solver.FilesystemPackageLoader{
+		Logger:  log.New(ioutil.Discard, "", 0),
+		Root:    root,
+		Context: options.GetVariables(),
+	})
+	require.NoError(t, err)
+
+	graph, err := packages.Resolve(target)
+	require.NoError(t, err)
+
+	return graph
+}
+
+// scripts returns step instructions of all the exec ops of the state.
+func scripts(t *testing.T, state llb.State) []string {
+	const prefix = "set -eou pipefail\n"
+
+	var result []string
+
+	for _, op := range marshalOps(t, state) {
+		if exec := op.GetExec(); exec != nil && len(exec.Meta.Args) == 3 && strings.HasPrefix(exec.Meta.Args[2], prefix) {
+			result = append(result, strings.TrimPrefix(exec.Meta.Args[2], prefix))
+		}
+	}
+
+	return result
+}
+
+func TestPlatformDependencyTemplates(t *testing.T) {
+	options := &environment.Options{
+		BuildPlatform:  environment.LinuxAmd64,
+		TargetPlatform: environment.LinuxArm64,
+	}
+
+	graph := loadGraph(t, map[string]string{
+		"Pkgfile": "format: v1alpha2\n",
+		"tool/pkg.yaml": `name: tool
+steps:
+  - build:
+      - echo tool {{ .ARCH }}
+  - when: eq .ARCH "aarch64"
+    build:
+      - echo tool arm
+finalize:
+  - from: /
+`,
+		"final/pkg.yaml": `name: final
+dependencies:
+  - stage: tool
+    platform: build
+    to: /host
+  - stage: tool
+steps:
+  - build:
+      - echo final {{ .ARCH }}
+finalize:
+  - from: /
+`,
+	}, "final", options)
+
+	state, err := BuildLLB(graph, options)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"echo tool x86_64",
+		"echo tool aarch64",
+		"echo tool arm",
+		"echo final aarch64",
+	}, scripts(t, state))
+}
+
+// fakeResolver resolves image configs from the static labels.
+type fakeResolver struct {
+	labels map[string]map[string]string
+	calls  []string
+}
+
+func (r *fakeResolver) ResolveImageConfig(_ context.Context, ref string, _ llb.ResolveImageConfigOpt) (digest.Digest, []byte, error) {
+	r.calls = append(r.calls, ref)
+
+	var img specs.Image
+
+	img.Config.Labels = r.labels[ref]
+
+	config, err := json.Marshal(img)
+
+	return "", config, err
+}
+
+// copies returns `src -> dest` of all the copy actions of the state.
+func copies(t *testing.T, state llb.State) []string {
+	var result []string
+
+	for _, op := range marshalOps(t, state) {
+		for _, action := range op.GetFile().GetActions() {
+			if cp := action.GetCopy(); cp != nil {
+				result = append(result, cp.Src+" -> "+cp.Dest)
+			}
+		}
+	}
+
+	return result
+}
+
+func TestResolveLocation(t *testing.T) {
+	resolver := &fakeResolver{
+		labels: map[string]map[string]string{
+			"docker.io/library/toolchain:latest": {
+				constants.LabelName: "toolchain",
+				constants.LabelTo:   "/toolchain",
+			},
+			"docker.io/library/alpine:3.14": {
+				constants.LabelTo: "/alpine",
+			},
+		},
+	}
+
+	options := &environment.Options{
+		BuildPlatform:  environment.LinuxAmd64,
+		TargetPlatform: environment.LinuxAmd64,
+		MetaResolver:   resolver,
+	}
+
+	graph := loadGraph(t, map[string]string{
+		"Pkgfile": "format: v1alpha2\n",
+		"final/pkg.yaml": `name: final
+dependencies:
+  - image: toolchain
+  - image: alpine:3.14
+  - image: tools
+    to: /tools
+steps:
+  - build:
+      - echo final
+finalize:
+  - from: /
+`,
+	}, "final", options)
+
+	state, err := BuildLLB(graph, options)
+	require.NoError(t, err)
+
+	// dependency with explicit `to` is not resolved
+	assert.ElementsMatch(t, []string{"docker.io/library/toolchain:latest", "docker.io/library/alpine:3.14"}, resolver.calls)
+
+	result := copies(t, state)
+
+	assert.Contains(t, result, "/toolchain -> /toolchain")
+	assert.Contains(t, result, "/ -> /tools")
+	// image without bldr labels is copied to the root
+	assert.Contains(t, result, "/ -> /")
+	assert.NotContains(t, result, "/alpine -> /alpine")
+
+	// without resolver, dependencies are copied to the root
+	options.MetaResolver = nil
+
+	state, err = BuildLLB(graph, options)
+	require.NoError(t, err)
+
+	assert.NotContains(t, copies(t, state), "/toolchain -> /toolchain")
+}
+
+func TestStepIndexAfterConditions(t *testing.T) {
+	options := &environment.Options{
+		BuildPlatform:  environment.LinuxAmd64,
+		TargetPlatform: environment.LinuxAmd64,
+	}
+
+	graph := loadGraph(t, map[string]string{
+		"Pkgfile": "format: v1alpha2\n",
+		"test/pkg.yaml": `name: test
+steps:
+  - when: eq .ARCH "aarch64"
+    build:
+      - echo arm
+  - build:
+      - echo all
+finalize:
+  - from: /
+`,
+	}, "test", options)
+
+	state, err := BuildLLB(graph, options)
+	require.NoError(t, err)
+
+	def, err := state.Marshal(context.Background())
+	require.NoError(t, err)
+
+	var names []string
+
+	for _, meta := range def.Metadata {
+		if name, ok := meta.Description["llb.customname"]; ok {
+			names = append(names, name)
+		}
+	}
+
+	// step name and directory use the index in pkg.yaml
+	assert.Contains(t, names, "test:build-1")
+	assert.Contains(t, names, "test:mkdir /tmp/build/1")
+	assert.NotContains(t, names, "test:build-0")
+}
@@ -6,20 +6,22 @@ package convert
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/alessio/shellescape"
 	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
 	"github.com/opencontainers/go-digest"
 	"github.com/talos-systems/bldr/internal/pkg/constants"
 	"github.com/talos-systems/bldr/internal/pkg/solver"
 	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
 )
 
-const (
-	tmpDirTemplate = "/tmp/build/%d"
-	pkgDir         = "/pkg"
-)
+const pkgDir = "/pkg"
 
 var defaultCopyOptions = &llb.CopyInfo{
 	CopyDirContentsOnly: true,
@@ -48,31 +50,82 @@ func NewNodeLLB(node *solver.PackageNode, graph *GraphLLB) *NodeLLB {
 }
 
 func (node *NodeLLB) base() (llb.State, error) {
-	if node.Pkg.Variant == v1alpha2.Scratch && len(node.Dependencies) > 0 {
+	spec, ok := node.Graph.Variants[node.Pkg.Variant]
+	if !ok {
+		return llb.Scratch(), fmt.Errorf("package %q: unknown variant %q", node.Name, node.Pkg.Variant)
+	}
+
+	if node.Base != nil {
+		depState, srcName, err := node.convertDependency(*node.Base)
+		if err != nil {
+			return llb.Scratch(), err
+		}
+
+		if node.Base.From != "" || node.Base.To != "" || len(node.Base.Paths) > 0 {
+			depState = copyDependency(llb.Scratch(), *node.Base, depState, srcName)
+		}
+
+		return node.Graph.baseImageProcessor(depState), nil
+	}
+
+	if spec.IsScratch() && len(node.Dependencies) > 0 {
 		// pull the first dependency as base image if the package build is from scratch
+		// (deprecated, explicit base should be used instead)
 		promotedDep := node.Dependencies[0]
 		node.promotedDependency = promotedDep.ID()
 
-		depState, _, err := node.convertDependency(promotedDep)
+		depState, srcName, err := node.convertDependency(promotedDep)
+		if err != nil {
+			return llb.Scratch(), err
+		}
+
+		if promotedDep.From != "" || len(promotedDep.Paths) > 0 {
+			// only part of the dependency is used as the base
+			depState = copyDependency(llb.Scratch(), promotedDep, depState, srcName)
+		}
 
-		return node.Graph.baseImageProcessor(depState), err
+		return node.Graph.baseImageProcessor(depState), nil
 	}
 
 	return node.Graph.BaseImages[node.Pkg.Variant], nil
 }
 
 func (node *NodeLLB) install(root llb.State) llb.State {
-	if len(node.Pkg.Install) > 0 {
-		root = root.Run(
-			append(node.Graph.commonRunOptions,
-				llb.Args(
-					append([]string{"/sbin/apk", "add", "--no-cache"}, node.Pkg.Install...)),
-				llb.WithCustomName(node.Prefix+"apk-install"),
-			)...,
-		).Root()
+	if len(node.Pkg.Install) == 0 {
+		return root
 	}
 
-	return root
+	spec := node.Graph.Variants[node.Pkg.Variant]
+
+	pm := packageManagers[spec.PackageManager]
+	if pm == nil {
+		// validated while loading packages
+		return root
+	}
+
+	runOptions := append([]llb.RunOption{}, node.Graph.commonRunOptions...)
+
+	if spec.Cache {
+		for _, dir := range pm.cacheDirs {
+			runOptions = append(runOptions,
+				llb.AddMount(
+					dir,
+					llb.Scratch(),
+					llb.AsPersistentCacheDir(
+						fmt.Sprintf("bldr-%s-%s-%s", node.Pkg.Variant, node.Graph.Options.BuildPlatform.ID, dir),
+						llb.CacheMountLocked,
+					),
+				),
+			)
+		}
+	}
+
+	return root.Run(
+		append(runOptions,
+			llb.Args([]string{"/bin/sh", "-c", pm.installScript(pm.packages(node.Pkg.Install), spec.Cache)}),
+			llb.WithCustomName(node.Prefix+string(spec.PackageManager)+"-install"),
+		)...,
+	).Root()
 }
 
 func (node *NodeLLB) context(root llb.State) llb.State {
@@ -84,22 +137,85 @@ func (node *NodeLLB) context(root llb.State) llb.State {
 	)
 }
 
+// platformDependency returns the graph for the dependency platform, and the dependency
+// with the internal node loaded for that platform.
+func (node *NodeLLB) platformDependency(dep solver.PackageDependency) (solver.PackageDependency, *GraphLLB, error) {
+	graph, err := node.Graph.forPlatform(dep.Platform)
+	if err != nil {
+		return dep, nil, err
+	}
+
+	if dep.IsInternal() && graph != node.Graph {
+		if dep.Node, err = graph.Node(dep.StageName()); err != nil {
+			return dep, nil, err
+		}
+	}
+
+	return dep, graph, nil
+}
+
 func (node *NodeLLB) convertDependency(dep solver.PackageDependency) (depState llb.State, srcName string, err error) {
+	dep, graph, err := node.platformDependency(dep)
+	if err != nil {
+		return llb.Scratch(), "", err
+	}
+
 	if dep.IsInternal() {
-		depState, err = NewNodeLLB(dep.Node, node.Graph).Build()
+		depState, err = NewNodeLLB(dep.Node, graph).BuildOutput(dep.Output())
 		if err != nil {
 			return llb.Scratch(), "", err
 		}
 
-		srcName = dep.Node.Name
+		srcName = dep.Stage
 	} else {
-		depState = llb.Image(dep.Image)
+		depState = llb.Image(dep.Image, graph.Options.BuildPlatform.LLBPlatform)
 		srcName = dep.Image
 	}
 
+	if graph != node.Graph {
+		srcName += " (" + graph.Options.TargetPlatform.ID + ")"
+	}
+
 	return
 }
 
+// resolveLocation sets `to` (and `from`, if not set) of the external dependency without `to` from the image labels.
+//
+// If the location can't be resolved, dependency is copied to `/` as before.
+func (node *NodeLLB) resolveLocation(dep solver.PackageDependency) (solver.PackageDependency, error) {
+	if dep.IsInternal() || dep.To != "" || len(dep.Paths) > 0 {
+		return dep, nil
+	}
+
+	graph, err := node.Graph.forPlatform(dep.Platform)
+	if err != nil {
+		return dep, err
+	}
+
+	location, err := graph.imageLocation(dep.Image)
+	if err != nil || location == "" {
+		return dep, err
+	}
+
+	if dep.From == "" {
+		dep.From = location
+	}
+
+	dep.To = location
+
+	return dep, nil
+}
+
+func copyDependency(root llb.State, dep solver.PackageDependency, depState llb.State, srcName string) llb.State {
+	for _, p := range dep.Copies() {
+		root = root.File(
+			llb.Copy(depState, p.From, p.To, defaultCopyOptions),
+			llb.WithCustomNamef("copy --from %s %s -> %s", srcName, p.From, p.To))
+	}
+
+	return root
+}
+
 func (node *NodeLLB) dependencies(root llb.State) (llb.State, error) {
 	deps := make([]solver.PackageDependency, 0, len(node.Dependencies))
 
@@ -110,10 +226,39 @@ func (node *NodeLLB) dependencies(root llb.State) (llb.State, error) {
 	// but due to deduplication all the duplicates are removed (only first appearance
 	// stays in the list)
 
+	addDependency := func(dep solver.PackageDependency, direct bool) error {
+		// runtime dependencies are taken from the package loaded for the dependency platform
+		dep, _, err := node.platformDependency(dep)
+		if err != nil {
+			return err
+		}
+
+		if direct {
+			deps = append(deps, dep)
+		}
+
+		for _, runtimeDep := range dep.RuntimeDependencies() {
+			if runtimeDep.Platform == v1alpha2.PlatformDefault {
+				// runtime dependencies follow the platform of the dependency
+				runtimeDep.Platform = dep.Platform
+			}
+
+			deps = append(deps, runtimeDep)
+		}
+
+		return nil
+	}
+
+	if node.Base != nil {
+		// base itself is the root filesystem, but its runtime dependencies are pulled in
+		if err := addDependency(*node.Base, false); err != nil {
+			return llb.Scratch(), err
+		}
+	}
+
 	for _, dep := range node.Dependencies {
-		deps = append(deps, dep)
-		if dep.Node != nil {
-			deps = append(deps, dep.Node.RuntimeDependencies()...)
+		if err := addDependency(dep, true); err != nil {
+			return llb.Scratch(), err
 		}
 	}
 
@@ -131,59 +276,280 @@ func (node *NodeLLB) dependencies(root llb.State) (llb.State, error) {
 			continue
 		}
 
+		var err error
+
+		// external dependencies built by bldr have the location recorded in the labels
+		if dep, err = node.resolveLocation(dep); err != nil {
+			return llb.Scratch(), err
+		}
+
 		depState, srcName, err := node.convertDependency(dep)
 		if err != nil {
 			return llb.Scratch(), err
 		}
 
-		root = root.File(
-			llb.Copy(depState, dep.Src(), dep.Dest(), defaultCopyOptions),
-			llb.WithCustomNamef("copy --from %s %s -> %s", srcName, dep.Src(), dep.Dest()))
+		root = copyDependency(root, dep, depState, srcName)
 	}
 
 	return root, nil
 }
 
-func (node *NodeLLB) stepTmpDir(root llb.State, i int, step *v1alpha2.Step) llb.State {
+func (node *NodeLLB) stepCopyOptions(step v1alpha2.Step) []llb.CopyOption {
+	copyOptions := []llb.CopyOption{defaultCopyOptions}
+
+	if step.User != "" {
+		copyOptions = append(copyOptions, llb.WithUser(step.User))
+	}
+
+	return copyOptions
+}
+
+func (node *NodeLLB) stepTmpDir(root llb.State, step *v1alpha2.Step) llb.State {
 	if step.TmpDir == "" {
-		step.TmpDir = fmt.Sprintf(tmpDirTemplate, i)
+		step.TmpDir = step.Workdir
+	}
+
+	if step.TmpDir == "" {
+		step.TmpDir = fmt.Sprintf(constants.StepTmpDirTemplate, step.Index)
+	}
+
+	mkdirOptions := []llb.MkdirOption{llb.WithParents(true)}
+
+	if step.User != "" {
+		mkdirOptions = append(mkdirOptions, llb.WithUser(step.User))
 	}
 
 	return root.File(
-		llb.Mkdir(step.TmpDir, constants.DefaultDirMode, llb.WithParents(true)),
+		llb.Mkdir(step.TmpDir, constants.DefaultDirMode, mkdirOptions...),
 		llb.WithCustomName(node.Prefix+"mkdir "+step.TmpDir),
 	).Dir(step.TmpDir)
 }
 
 func (node *NodeLLB) stepDownload(root llb.State, step v1alpha2.Step) llb.State {
 	for _, source := range step.Sources {
-		download := llb.HTTP(
-			source.URL,
-			llb.Filename(filepath.Join("/", source.Destination)),
-			llb.Checksum(digest.NewDigestFromEncoded(digest.SHA256, source.SHA256)),
+		if source.IsGit() {
+			root = node.stepDownloadGit(root, step, source)
+
+			continue
+		}
+
+		if source.IsFile() {
+			root = node.stepDownloadFile(root, step, source)
+
+			continue
+		}
+
+		root = node.stepDownloadHTTP(root, step, source)
+	}
+
+	return root
+}
+
+func (node *NodeLLB) stepDownloadGit(root llb.State, step v1alpha2.Step, source v1alpha2.Source) llb.State {
+	gitOptions := []llb.GitOption{
+		llb.WithCustomNamef(node.Prefix+"git %s@%s -> %s", source.Git, source.Commit, source.Destination),
+	}
+
+	// .git directory is required to remove submodules
+	if source.KeepGitDir || !source.CheckoutSubmodules() {
+		gitOptions = append(gitOptions, llb.KeepGitDir())
+	}
+
+	if len(step.SSH) > 0 {
+		// use step SSH agent for git+ssh repositories
+		gitOptions = append(gitOptions, llb.MountSSHSock(step.SSH[0].SSHID()))
+	}
+
+	checkout := llb.Git(source.Git, source.Commit, gitOptions...)
+
+	if !source.CheckoutSubmodules() {
+		checkout = node.removeSubmodules(source, checkout)
+	}
+
+	return root.File(
+		llb.Copy(checkout, "/", filepath.Join(step.TmpDir, source.Destination), node.stepCopyOptions(step)...),
+		llb.WithCustomName(node.Prefix+"git finalize"),
+	)
+}
+
+// removeSubmodules removes submodules from the git checkout, as buildkit always checks them out.
+func (node *NodeLLB) removeSubmodules(source v1alpha2.Source, checkout llb.State) llb.State {
+	const srcDir = "/src"
+
+	script := "git submodule deinit --all --force"
+
+	if !source.KeepGitDir {
+		script += " && rm -rf .git"
+	}
+
+	return node.Graph.GitTool.Run(
+		append(node.Graph.commonRunOptions,
+			llb.Args([]string{"/bin/sh", "-c", script}),
+			llb.Dir(srcDir),
+			llb.WithCustomNamef(node.Prefix+"git %s remove submodules", source.Git),
+		)...,
+	).AddMount(srcDir, checkout)
+}
+
+func (node *NodeLLB) stepDownloadHTTP(root llb.State, step v1alpha2.Step, source v1alpha2.Source) llb.State {
+	if candidates := node.Graph.mirrors().Candidates(source.URL); len(candidates) > 1 {
+		return node.stepVerifySource(root, step, source, node.downloadMirrors(source, candidates), true)
+	}
+
+	download := llb.HTTP(
+		source.URL,
+		llb.Filename(filepath.Join("/", source.Destination)),
+		llb.Checksum(digest.NewDigestFromEncoded(digest.SHA256, source.SHA256)),
+		llb.WithCustomNamef(node.Prefix+"download %s -> %s", source.URL, source.Destination),
+	)
+
+	// sha256 is verified by buildkit as part of the download
+	return node.stepVerifySource(root, step, source, download, false)
+}
+
+// downloadMirrors downloads the source trying candidate URLs in order,
+// content which doesn't match checksums is discarded.
+func (node *NodeLLB) downloadMirrors(source v1alpha2.Source, candidates []string) llb.State {
+	const outDir = "/out"
+
+	urls := make([]string, len(candidates))
+	for i := range candidates {
+		urls[i] = shellescape.Quote(candidates[i])
+	}
+
+	dest := shellescape.Quote(source.Destination)
+
+	// destination might be in a subdirectory
+	script := fmt.Sprintf(`mkdir -p "$(dirname %s)"
+for url in %s; do
+  if wget -q -O %s "$url" && sha256sum -c --strict /checksums256 && sha512sum -c --strict /checksums; then
+    exit 0
+  fi
+  echo "failed to download $url" >&2
+  rm -f %s
+done
+exit 1`, dest, strings.Join(urls, " "), dest, dest)
+
+	return node.Graph.Checksummer.File(
+		llb.Mkfile("/checksums", 0644, source.ToSHA512Sum()).
+			Mkfile("/checksums256", 0644, source.ToSHA256Sum()),
+		llb.WithCustomName(node.Prefix+"mirror-prepare"),
+	).Run(
+		append(node.Graph.commonRunOptions,
+			llb.Args([]string{"/bin/sh", "-c", v1alpha2.Instruction(script).Script()}),
+			llb.Dir(outDir),
 			llb.WithCustomNamef(node.Prefix+"download %s -> %s", source.URL, source.Destination),
-		)
+		)...,
+	).AddMount(outDir, llb.Scratch())
+}
+
+func (node *NodeLLB) stepDownloadFile(root llb.State, step v1alpha2.Step, source v1alpha2.Source) llb.State {
+	contextPath := source.ContextPath(node.Pkg.BaseDir)
+
+	file := llb.Scratch().File(
+		llb.Copy(node.Graph.LocalContext, contextPath, filepath.Join("/", source.Destination), defaultCopyOptions),
+		llb.WithCustomNamef(node.Prefix+"file %s -> %s", contextPath, source.Destination),
+	)
+
+	return node.stepVerifySource(root, step, source, file, true)
+}
+
+func (node *NodeLLB) stepVerifySource(root llb.State, step v1alpha2.Step, source v1alpha2.Source, src llb.State, verifySHA256 bool) llb.State {
+	checksums := llb.Mkfile("/checksums", 0644, source.ToSHA512Sum())
+	verify := llb.Shlex("sha512sum -c --strict /checksums")
+
+	if verifySHA256 {
+		checksums = checksums.Mkfile("/checksums256", 0644, source.ToSHA256Sum())
+		verify = llb.Args([]string{"/bin/sh", "-c", "sha256sum -c --strict /checksums256 && sha512sum -c --strict /checksums"})
+	}
 
-		checksummer := node.Graph.Checksummer.File(
-			llb.Mkfile("/checksums", 0644, source.ToSHA512Sum()).
-				Copy(download, "/", "/", defaultCopyOptions).
-				Mkdir("/empty", constants.DefaultDirMode),
-			llb.WithCustomName(node.Prefix+"cksum-prepare"),
-		).Run(
+	checksummer := node.Graph.Checksummer.File(
+		checksums.
+			Copy(src, "/", "/", defaultCopyOptions).
+			Mkdir("/empty", constants.DefaultDirMode),
+		llb.WithCustomName(node.Prefix+"cksum-prepare"),
+	).Run(
+		append(node.Graph.commonRunOptions,
+			verify,
+			llb.WithCustomName(node.Prefix+"cksum-verify"),
+		)...,
+	).Root()
+
+	dest := step.TmpDir
+
+	if source.Extract {
+		src = node.extract(source, src)
+		dest = filepath.Join(step.TmpDir, source.Subdir)
+	}
+
+	return root.File(
+		llb.Copy(src, "/", dest, node.stepCopyOptions(step)...).
+			Copy(checksummer, "/empty", "/", defaultCopyOptions), // TODO: this is "fake" dependency on checksummer
+		llb.WithCustomName(node.Prefix+"download finalize"),
+	)
+}
+
+func (node *NodeLLB) extract(source v1alpha2.Source, src llb.State) llb.State {
+	const (
+		srcDir = "/src"
+		outDir = "/out"
+	)
+
+	return node.Graph.Extractor.Run(
+		append(node.Graph.commonRunOptions,
+			llb.Args([]string{
+				"bsdtar", "-x",
+				"-f", filepath.Join(srcDir, source.Destination),
+				"-C", outDir,
+				"--strip-components", strconv.Itoa(source.StripComponents),
+			}),
+			llb.AddMount(srcDir, src, llb.Readonly),
+			llb.WithCustomNamef(node.Prefix+"extract %s", source.Destination),
+		)...,
+	).AddMount(outDir, llb.Scratch())
+}
+
+func (node *NodeLLB) stepPatches(root llb.State, step v1alpha2.Step) llb.State {
+	patches := step.Patches.ForPlatform(node.Graph.Options.TargetPlatform.ID)
+	if len(patches) == 0 {
+		return root
+	}
+
+	const (
+		workDir    = "/work"
+		contextDir = "/context"
+	)
+
+	work := llb.Scratch().File(
+		llb.Copy(root, step.TmpDir, "/", defaultCopyOptions),
+		llb.WithCustomName(node.Prefix+"patch prepare"),
+	)
+
+	for _, patch := range patches {
+		patchPath := filepath.Join(contextDir, patch.ContextPath(node.Pkg.BaseDir))
+
+		work = node.Graph.Patcher.Run(
 			append(node.Graph.commonRunOptions,
-				llb.Shlex("sha512sum -c --strict /checksums"),
-				llb.WithCustomName(node.Prefix+"cksum-verify"),
+				llb.Args([]string{
+					"/bin/sh", "-c",
+					fmt.Sprintf("patch -p%d -d %s -i %s || { echo %s >&2; exit 1; }",
+						patch.StripLevel(),
+						workDir,
+						shellescape.Quote(patchPath),
+						shellescape.Quote("failed to apply patch "+patch.File),
+					),
+				}),
+				llb.AddMount(contextDir, node.Graph.LocalContext, llb.Readonly),
+				llb.WithCustomName(node.Prefix+"patch "+patch.File),
 			)...,
-		).Root()
-
-		root = root.File(
-			llb.Copy(download, "/", step.TmpDir, defaultCopyOptions).
-				Copy(checksummer, "/empty", "/", defaultCopyOptions), // TODO: this is "fake" dependency on checksummer
-			llb.WithCustomName(node.Prefix+"download finalize"),
-		)
+		).AddMount(workDir, work)
 	}
 
-	return root
+	return root.File(
+		llb.Rm(step.TmpDir).
+			Copy(work, "/", step.TmpDir, node.stepCopyOptions(step)...),
+		llb.WithCustomName(node.Prefix+"patch finalize"),
+	)
 }
 
 func (node *NodeLLB) stepEnvironment(root llb.State, step v1alpha2.Step) llb.State {
@@ -203,7 +569,77 @@ func (node *NodeLLB) stepEnvironment(root llb.State, step v1alpha2.Step) llb.Sta
 	return root
 }
 
-func (node *NodeLLB) stepScripts(root llb.State, i int, step v1alpha2.Step) llb.State {
+func cacheSharingMode(sharing string) llb.CacheMountSharingMode {
+	switch sharing {
+	case v1alpha2.CacheSharingPrivate:
+		return llb.CacheMountPrivate
+	case v1alpha2.CacheSharingLocked:
+		return llb.CacheMountLocked
+	default:
+		return llb.CacheMountShared
+	}
+}
+
+func (node *NodeLLB) stepNetwork(step v1alpha2.Step) v1alpha2.Network {
+	if pkgfile := node.Graph.Pkgfile; pkgfile != nil && pkgfile.Hermetic {
+		// explicit network access is rejected while loading packages
+		return v1alpha2.NetworkNone
+	}
+
+	if step.Network != v1alpha2.NetworkDefault {
+		return step.Network
+	}
+
+	return node.Pkg.Network
+}
+
+func (node *NodeLLB) stepRunOptions(step v1alpha2.Step) []llb.RunOption {
+	runOptions := append([]llb.RunOption(nil), node.Graph.commonRunOptions...)
+
+	for _, mount := range step.Cache {
+		runOptions = append(runOptions,
+			llb.AddMount(
+				mount.Target,
+				llb.Scratch(),
+				llb.AsPersistentCacheDir(mount.CacheID(), cacheSharingMode(mount.Sharing)),
+			),
+		)
+	}
+
+	for _, secret := range step.Secrets {
+		runOptions = append(runOptions,
+			llb.AddSecret(secret.Path(), llb.SecretID(secret.ID)),
+		)
+	}
+
+	for i, mount := range step.SSH {
+		runOptions = append(runOptions,
+			llb.AddSSHSocket(llb.SSHID(mount.SSHID()), llb.SSHSocketTarget(mount.Path(i))),
+		)
+	}
+
+	if authSock := step.SSH.AuthSock(); authSock != "" {
+		runOptions = append(runOptions, llb.AddEnv("SSH_AUTH_SOCK", authSock))
+	}
+
+	if step.User != "" {
+		runOptions = append(runOptions, llb.User(step.User))
+	}
+
+	switch node.stepNetwork(step) {
+	case v1alpha2.NetworkNone:
+		runOptions = append(runOptions, llb.Network(pb.NetMode_NONE))
+	case v1alpha2.NetworkHost:
+		runOptions = append(runOptions, llb.Network(pb.NetMode_HOST))
+	}
+
+	return runOptions
+}
+
+func (node *NodeLLB) stepScripts(root llb.State, step v1alpha2.Step) llb.State {
+	runOptions := node.stepRunOptions(step)
+	exports := step.Secrets.Exports()
+
 	for _, script := range []struct {
 		Desc         string
 		Instructions v1alpha2.Instructions
@@ -215,13 +651,13 @@ func (node *NodeLLB) stepScripts(root llb.State, i int, step v1alpha2.Step) llb.
 	} {
 		for _, instruction := range script.Instructions {
 			root = root.Run(
-				append(node.Graph.commonRunOptions,
+				append(runOptions,
 					llb.Args([]string{
 						node.Pkg.Shell.Get(),
 						"-c",
-						instruction.Script(),
+						v1alpha2.Instruction(exports + string(instruction)).Script(),
 					}),
-					llb.WithCustomName(fmt.Sprintf("%s%s-%d", node.Prefix, script.Desc, i)),
+					llb.WithCustomName(fmt.Sprintf("%s%s-%s", node.Prefix, script.Desc, step.DisplayName())),
 				)...,
 			).Root()
 		}
@@ -230,22 +666,75 @@ func (node *NodeLLB) stepScripts(root llb.State, i int, step v1alpha2.Step) llb.
 	return root
 }
 
-func (node *NodeLLB) step(root llb.State, i int, step v1alpha2.Step) llb.State {
-	root = node.stepTmpDir(root, i, &step)
+func (node *NodeLLB) step(root llb.State, step v1alpha2.Step) llb.State {
+	root = node.stepTmpDir(root, &step)
 	root = node.stepDownload(root, step)
+	root = node.stepPatches(root, step)
 	root = node.stepEnvironment(root, step)
-	root = node.stepScripts(root, i, step)
+	root = node.stepScripts(root, step)
 
 	return root
 }
 
-func (node *NodeLLB) finalize(root llb.State) llb.State {
+// finalizeCopyInfo returns copy options to copy finalized paths from the build root.
+func finalizeCopyInfo(fin *v1alpha2.Finalize) *llb.CopyInfo {
+	copyInfo := *defaultCopyOptions
+
+	copyInfo.IncludePatterns = fin.Include
+	copyInfo.ExcludePatterns = fin.Exclude
+	copyInfo.FollowSymlinks = fin.FollowSymlinks()
+
+	return &copyInfo
+}
+
+func finalizeCopyOptions(copyInfo *llb.CopyInfo, fin *v1alpha2.Finalize) []llb.CopyOption {
+	copyOptions := []llb.CopyOption{copyInfo}
+
+	if fin.Owner != "" {
+		copyOptions = append(copyOptions, llb.WithUser(fin.Owner))
+	}
+
+	return copyOptions
+}
+
+// finalizeFileMode copies finalized paths into a separate state and sets the mode of the regular files,
+// as buildkit copy sets the mode for directories as well.
+func (node *NodeLLB) finalizeFileMode(root llb.State, fin *v1alpha2.Finalize, mode os.FileMode, prefix string) llb.State {
+	const workDir = "/work"
+
+	staged := llb.Scratch().File(
+		llb.Copy(root, fin.From, fin.To, finalizeCopyInfo(fin)),
+		llb.WithCustomNamef(prefix+"finalize stage %s -> %s", fin.From, fin.To),
+	)
+
+	return node.Graph.Utils.Run(
+		append(node.Graph.commonRunOptions,
+			llb.Args([]string{"find", workDir, "-type", "f", "-exec", "chmod", fmt.Sprintf("%04o", mode), "{}", "+"}),
+			llb.WithCustomNamef(prefix+"finalize chmod %04o %s", mode, fin.To),
+		)...,
+	).AddMount(workDir, staged)
+}
+
+func (node *NodeLLB) finalize(root llb.State, finalize []v1alpha2.Finalize, prefix string) llb.State {
 	newroot := llb.Scratch()
 
-	for _, fin := range node.Pkg.Finalize {
+	for i := range finalize {
+		fin := &finalize[i]
+
+		src, from, copyInfo := root, fin.From, finalizeCopyInfo(fin)
+
+		if mode, _ := fin.FileMode(); mode != nil { //nolint:errcheck // validated on load
+			// staged copy is already filtered, and it keeps `to` as a symlink if the symlink is preserved
+			src, from = node.finalizeFileMode(root, fin, *mode, prefix), fin.To
+
+			stagedCopyInfo := *defaultCopyOptions
+			stagedCopyInfo.FollowSymlinks = false
+			copyInfo = &stagedCopyInfo
+		}
+
 		newroot = newroot.File(
-			llb.Copy(root, fin.From, fin.To, defaultCopyOptions),
-			llb.WithCustomNamef(node.Prefix+"finalize %s -> %s", fin.From, fin.To),
+			llb.Copy(src, from, fin.To, finalizeCopyOptions(copyInfo, fin)...),
+			llb.WithCustomNamef(prefix+"finalize %s -> %s", fin.From, fin.To),
 		)
 	}
 
@@ -254,6 +743,28 @@ func (node *NodeLLB) finalize(root llb.State) llb.State {
 
 // Build converts PackageNode to buildkit LLB.
 func (node *NodeLLB) Build() (llb.State, error) {
+	return node.BuildOutput("")
+}
+
+// BuildOutput converts PackageNode output to buildkit LLB.
+//
+// All the outputs share the same build root, empty output name means main package output.
+func (node *NodeLLB) BuildOutput(output string) (llb.State, error) {
+	root, err := node.buildRoot()
+	if err != nil {
+		return llb.Scratch(), err
+	}
+
+	if output == "" {
+		return node.finalize(root, node.Pkg.Finalize, node.Prefix), nil
+	}
+
+	prefix := node.Graph.Options.CommonPrefix + node.Name + v1alpha2.OutputSeparator + output + ":"
+
+	return node.finalize(root, node.Pkg.Outputs[output].Finalize, prefix), nil
+}
+
+func (node *NodeLLB) buildRoot() (llb.State, error) {
 	if state, ok := node.Graph.cache[node.PackageNode]; ok {
 		return state, nil
 	}
@@ -271,12 +782,10 @@ func (node *NodeLLB) Build() (llb.State, error) {
 	root = node.install(root)
 	root = node.context(root)
 
-	for i, step := range node.Pkg.Steps {
-		root = node.step(root, i, step)
+	for _, step := range node.Pkg.Steps {
+		root = node.step(root, step)
 	}
 
-	root = node.finalize(root)
-
 	node.Graph.cache[node.PackageNode] = root
 
 	return root, nil
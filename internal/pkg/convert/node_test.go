@@ -0,0 +1,331 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package convert
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/environment"
+	"github.com/talos-systems/bldr/internal/pkg/solver"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func testNode(pkg *v1alpha2.Pkg, pkgfile *v1alpha2.Pkgfile) *NodeLLB {
+	node := &solver.PackageNode{
+		Pkg:  pkg,
+		Name: pkg.Name,
+	}
+
+	graph := NewGraphLLB(&solver.PackageGraph{Root: node, Pkgfile: pkgfile}, &environment.Options{
+		BuildPlatform:  environment.LinuxAmd64,
+		TargetPlatform: environment.LinuxAmd64,
+	})
+
+	return NewNodeLLB(node, graph)
+}
+
+// marshalOps returns all the LLB ops of the state.
+func marshalOps(t *testing.T, state llb.State) []*pb.Op {
+	def, err := state.Marshal(context.Background())
+	require.NoError(t, err)
+
+	ops := make([]*pb.Op, 0, len(def.Def))
+
+	for _, dt := range def.Def {
+		var op pb.Op
+
+		require.NoError(t, op.Unmarshal(dt))
+
+		ops = append(ops, &op)
+	}
+
+	return ops
+}
+
+// stepExec returns exec op of the step instruction with the run options of the step.
+func stepExec(t *testing.T, node *NodeLLB, step v1alpha2.Step) *pb.ExecOp {
+	state := llb.Scratch().Run(append(node.stepRunOptions(step), llb.Shlex("true"))...).Root()
+
+	for _, op := range marshalOps(t, state) {
+		if exec := op.GetExec(); exec != nil {
+			return exec
+		}
+	}
+
+	require.FailNow(t, "exec op not found")
+
+	return nil
+}
+
+// execMounts returns mounts of the exec op by destination.
+func execMounts(exec *pb.ExecOp, mountType pb.MountType) map[string]*pb.Mount {
+	mounts := map[string]*pb.Mount{}
+
+	for _, mount := range exec.Mounts {
+		if mount.MountType == mountType {
+			mounts[mount.Dest] = mount
+		}
+	}
+
+	return mounts
+}
+
+func TestStepCacheMounts(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{Name: "test"}, nil)
+
+	exec := stepExec(t, node, v1alpha2.Step{
+		Cache: v1alpha2.CacheMounts{
+			{Target: "/root/.cache/go-build"},
+			{Target: "/go/pkg/mod", ID: "go-mod", Sharing: v1alpha2.CacheSharingLocked},
+		},
+	})
+
+	mounts := execMounts(exec, pb.MountType_CACHE)
+	require.Len(t, mounts, 2)
+
+	require.Contains(t, mounts, "/root/.cache/go-build")
+	assert.Equal(t, "/root/.cache/go-build", mounts["/root/.cache/go-build"].CacheOpt.ID)
+	assert.Equal(t, pb.CacheSharingOpt_SHARED, mounts["/root/.cache/go-build"].CacheOpt.Sharing)
+
+	require.Contains(t, mounts, "/go/pkg/mod")
+	assert.Equal(t, "go-mod", mounts["/go/pkg/mod"].CacheOpt.ID)
+	assert.Equal(t, pb.CacheSharingOpt_LOCKED, mounts["/go/pkg/mod"].CacheOpt.Sharing)
+}
+
+func TestStepSSH(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{Name: "test"}, nil)
+
+	exec := stepExec(t, node, v1alpha2.Step{})
+	assert.Empty(t, execMounts(exec, pb.MountType_SSH))
+	assert.NotContains(t, exec.Meta.Env, "SSH_AUTH_SOCK=/run/buildkit/ssh_agent.0")
+
+	exec = stepExec(t, node, v1alpha2.Step{
+		SSH: v1alpha2.SSHMounts{
+			{},
+			{ID: "deploy", Target: "/run/deploy.sock"},
+		},
+	})
+
+	mounts := execMounts(exec, pb.MountType_SSH)
+	require.Len(t, mounts, 2)
+
+	require.Contains(t, mounts, "/run/buildkit/ssh_agent.0")
+	assert.Equal(t, "default", mounts["/run/buildkit/ssh_agent.0"].SSHOpt.ID)
+
+	require.Contains(t, mounts, "/run/deploy.sock")
+	assert.Equal(t, "deploy", mounts["/run/deploy.sock"].SSHOpt.ID)
+
+	assert.Contains(t, exec.Meta.Env, "SSH_AUTH_SOCK=/run/buildkit/ssh_agent.0")
+}
+
+func TestStepSecrets(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{Name: "test"}, nil)
+
+	step := v1alpha2.Step{
+		Secrets: v1alpha2.Secrets{
+			{ID: "token", Env: "TOKEN"},
+		},
+		Build: v1alpha2.Instructions{"make"},
+	}
+
+	// failure to read the secret is not masked by `export`
+	assert.Equal(t, []string{"TOKEN=\"$(cat /run/secrets/token)\"; export TOKEN\nmake"}, scripts(t, node.stepScripts(llb.Scratch(), step)))
+
+	mounts := execMounts(stepExec(t, node, step), pb.MountType_SECRET)
+	require.Contains(t, mounts, "/run/secrets/token")
+	assert.Equal(t, "token", mounts["/run/secrets/token"].SecretOpt.ID)
+}
+
+func TestStepNetwork(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{Name: "test", Network: v1alpha2.NetworkNone}, nil)
+
+	assert.Equal(t, pb.NetMode_NONE, stepExec(t, node, v1alpha2.Step{}).Network)
+	assert.Equal(t, pb.NetMode_HOST, stepExec(t, node, v1alpha2.Step{Network: v1alpha2.NetworkHost}).Network)
+	assert.Equal(t, pb.NetMode_UNSET, stepExec(t, node, v1alpha2.Step{Network: v1alpha2.NetworkSandbox}).Network)
+
+	// hermetic build disables network for all the steps
+	node = testNode(&v1alpha2.Pkg{Name: "test"}, &v1alpha2.Pkgfile{Hermetic: true})
+
+	assert.Equal(t, pb.NetMode_NONE, stepExec(t, node, v1alpha2.Step{}).Network)
+	assert.Equal(t, pb.NetMode_NONE, stepExec(t, node, v1alpha2.Step{Network: v1alpha2.NetworkNone}).Network)
+}
+
+func TestStepDownloadGitSubmodules(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{Name: "test"}, nil)
+
+	checkout := func(submodules *bool) (source *pb.SourceOp, execs []*pb.ExecOp) {
+		step := v1alpha2.Step{
+			TmpDir: "/tmp/build/0",
+			Sources: v1alpha2.Sources{
+				{
+					Git:         "https://github.com/talos-systems/bldr.git",
+					Commit:      "0123456789abcdef0123456789abcdef01234567",
+					Destination: "bldr",
+					Submodules:  submodules,
+				},
+			},
+		}
+
+		for _, op := range marshalOps(t, node.stepDownload(llb.Scratch(), step)) {
+			if src := op.GetSource(); src != nil && src.Identifier == "git://github.com/talos-systems/bldr.git#0123456789abcdef0123456789abcdef01234567" {
+				source = src
+			}
+
+			if exec := op.GetExec(); exec != nil && exec.Meta.Cwd == "/src" {
+				execs = append(execs, exec)
+			}
+		}
+
+		require.NotNil(t, source)
+
+		return source, execs
+	}
+
+	enabled, disabled := true, false
+
+	for _, submodules := range []*bool{nil, &enabled} {
+		source, execs := checkout(submodules)
+
+		assert.NotContains(t, source.Attrs, pb.AttrKeepGitDir)
+		assert.Empty(t, execs)
+	}
+
+	source, execs := checkout(&disabled)
+
+	assert.Equal(t, "true", source.Attrs[pb.AttrKeepGitDir])
+	require.Len(t, execs, 1)
+	assert.Equal(t, []string{"/bin/sh", "-c", "git submodule deinit --all --force && rm -rf .git"}, execs[0].Meta.Args)
+}
+
+func TestDownloadMirrors(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{Name: "test"}, nil)
+
+	source := v1alpha2.Source{
+		URL:         "https://example.com/src.tar.gz",
+		Destination: "dist/src.tar.gz",
+		SHA256:      "a",
+		SHA512:      "b",
+	}
+
+	state := node.downloadMirrors(source, []string{source.URL, "https://mirror.example.com/src.tar.gz"})
+
+	var script string
+
+	for _, op := range marshalOps(t, state) {
+		if exec := op.GetExec(); exec != nil && strings.Contains(exec.Meta.Args[len(exec.Meta.Args)-1], "wget") {
+			script = exec.Meta.Args[2]
+			assert.Equal(t, "/out", exec.Meta.Cwd)
+		}
+	}
+
+	// parent directory of the destination is created before the download
+	assert.Contains(t, script, "set -eou pipefail\nmkdir -p \"$(dirname dist/src.tar.gz)\"\nfor url in https://example.com/src.tar.gz https://mirror.example.com/src.tar.gz; do")
+	assert.Contains(t, script, "wget -q -O dist/src.tar.gz \"$url\"")
+}
+
+func TestStepUser(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{Name: "test"}, nil)
+
+	assert.Empty(t, stepExec(t, node, v1alpha2.Step{}).Meta.User)
+	assert.Equal(t, "build", stepExec(t, node, v1alpha2.Step{User: "build"}).Meta.User)
+
+	step := v1alpha2.Step{User: "build", Workdir: "/src"}
+
+	var mkdir *pb.FileActionMkDir
+
+	for _, op := range marshalOps(t, node.stepTmpDir(llb.Scratch(), &step)) {
+		if file := op.GetFile(); file != nil {
+			mkdir = file.Actions[0].GetMkdir()
+		}
+	}
+
+	require.NotNil(t, mkdir)
+	assert.Equal(t, "/src", mkdir.Path)
+	assert.Equal(t, "build", mkdir.Owner.User.GetByName().Name)
+}
+
+func TestFinalizeMode(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{Name: "test"}, nil)
+
+	finalizeOps := func(fin v1alpha2.Finalize) (copies []*pb.FileActionCopy, execs []*pb.ExecOp) {
+		for _, op := range marshalOps(t, node.finalize(llb.Image("alpine"), []v1alpha2.Finalize{fin}, "")) {
+			if file := op.GetFile(); file != nil {
+				copies = append(copies, file.Actions[0].GetCopy())
+			}
+
+			if exec := op.GetExec(); exec != nil {
+				execs = append(execs, exec)
+			}
+		}
+
+		return copies, execs
+	}
+
+	copies, execs := finalizeOps(v1alpha2.Finalize{From: "/rootfs", To: "/", Owner: "1000"})
+	assert.Empty(t, execs)
+	require.Len(t, copies, 1)
+	assert.Equal(t, int32(-1), copies[0].Mode)
+	assert.Equal(t, "/rootfs", copies[0].Src)
+
+	copies, execs = finalizeOps(v1alpha2.Finalize{From: "/rootfs", To: "/usr", Mode: "0644", Owner: "1000", Exclude: []string{"*.a"}})
+	require.Len(t, execs, 1)
+	assert.Equal(t, []string{"find", "/work", "-type", "f", "-exec", "chmod", "0644", "{}", "+"}, execs[0].Meta.Args)
+
+	// mode is never set by copy, as it applies to directories as well
+	require.Len(t, copies, 2)
+
+	for _, cp := range copies {
+		assert.Equal(t, int32(-1), cp.Mode)
+	}
+
+	// filters are applied when staging, owner is set by the final copy
+	staging, final := copies[0], copies[1]
+
+	if staging.Src != "/rootfs" {
+		staging, final = final, staging
+	}
+
+	assert.Equal(t, []string{"*.a"}, staging.ExcludePatterns)
+	assert.Nil(t, staging.Owner)
+	assert.Equal(t, "/usr", final.Src)
+	assert.Empty(t, final.ExcludePatterns)
+	assert.NotNil(t, final.Owner)
+}
+
+func TestInstallCache(t *testing.T) {
+	node := testNode(&v1alpha2.Pkg{
+		Name:    "test",
+		Variant: "debian",
+		Install: v1alpha2.Install{"curl"},
+	}, &v1alpha2.Pkgfile{
+		Variants: v1alpha2.Variants{
+			"debian": {
+				Image:          "debian:bullseye",
+				PackageManager: v1alpha2.PackageManagerAPT,
+				Cache:          true,
+			},
+		},
+	})
+
+	var mounts map[string]*pb.Mount
+
+	for _, op := range marshalOps(t, node.install(llb.Scratch())) {
+		if exec := op.GetExec(); exec != nil && strings.Contains(exec.Meta.Args[len(exec.Meta.Args)-1], "apt-get install") {
+			mounts = execMounts(exec, pb.MountType_CACHE)
+		}
+	}
+
+	// /var/lib/apt/extended_states should reach the image
+	assert.Len(t, mounts, 2)
+	assert.Contains(t, mounts, "/var/cache/apt")
+	assert.Contains(t, mounts, "/var/lib/apt/lists")
+}
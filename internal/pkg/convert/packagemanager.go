@@ -0,0 +1,110 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alessio/shellescape"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+// packageManager describes how packages are installed with the specific package manager.
+type packageManager struct {
+	// keysDir is a directory for repository signing keys.
+	keysDir string
+	// cacheDirs are mounted as persistent cache if cache is enabled.
+	cacheDirs []string
+	// pin formats pinned package for the install command.
+	pin func(name, version string) string
+	// installScript returns shell script to install (quoted) packages.
+	installScript func(pkgs string, cache bool) string
+	// repositoriesScript returns shell script to add repositories, keys are paths of the keys in the image.
+	repositoriesScript func(repos []string, keys []string) string
+}
+
+var packageManagers = map[v1alpha2.PackageManager]*packageManager{
+	v1alpha2.PackageManagerAPK: {
+		keysDir:   "/etc/apk/keys",
+		cacheDirs: []string{"/var/cache/apk"},
+		pin: func(name, version string) string {
+			return name + "=" + version
+		},
+		installScript: func(pkgs string, cache bool) string {
+			if cache {
+				return "apk add --update-cache --cache-dir /var/cache/apk " + pkgs
+			}
+
+			return "apk add --no-cache " + pkgs
+		},
+		repositoriesScript: func(repos []string, _ []string) string {
+			return "printf '%s\\n' " + shellescape.QuoteCommand(repos) + " >> /etc/apk/repositories"
+		},
+	},
+	v1alpha2.PackageManagerAPT: {
+		keysDir: "/etc/apt/trusted.gpg.d",
+		// installed package state in /var/lib/apt is kept in the image, only package lists are cached
+		cacheDirs: []string{"/var/cache/apt", "/var/lib/apt/lists"},
+		pin: func(name, version string) string {
+			return name + "=" + version
+		},
+		installScript: func(pkgs string, cache bool) string {
+			install := "apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y --no-install-recommends "
+
+			if cache {
+				return "rm -f /etc/apt/apt.conf.d/docker-clean && " + install + "-o APT::Keep-Downloaded-Packages=true " + pkgs
+			}
+
+			return install + pkgs + " && rm -rf /var/lib/apt/lists/*"
+		},
+		repositoriesScript: func(repos []string, _ []string) string {
+			return "printf '%s\\n' " + shellescape.QuoteCommand(repos) + " > /etc/apt/sources.list.d/bldr.list"
+		},
+	},
+	v1alpha2.PackageManagerDNF: {
+		keysDir:   "/etc/pki/rpm-gpg",
+		cacheDirs: []string{"/var/cache/dnf"},
+		pin: func(name, version string) string {
+			return name + "-" + version
+		},
+		installScript: func(pkgs string, cache bool) string {
+			if cache {
+				return "dnf install -y --setopt=install_weak_deps=False --setopt=keepcache=True " + pkgs
+			}
+
+			return "dnf install -y --setopt=install_weak_deps=False " + pkgs + " && dnf clean all"
+		},
+		repositoriesScript: func(repos []string, keys []string) string {
+			var sb strings.Builder
+
+			for i, repo := range repos {
+				fmt.Fprintf(&sb, "[bldr-%d]\nname=bldr-%d\nbaseurl=%s\nenabled=1\ngpgcheck=1\n", i, i, repo)
+
+				if len(keys) > 0 {
+					fmt.Fprintf(&sb, "gpgkey=file://%s\n", strings.Join(keys, " file://"))
+				}
+			}
+
+			return "printf '%s' " + shellescape.Quote(sb.String()) + " > /etc/yum.repos.d/bldr.repo"
+		},
+	},
+}
+
+// packages formats install list for the package manager.
+func (pm *packageManager) packages(install v1alpha2.Install) string {
+	pkgs := make([]string, 0, len(install))
+
+	for _, pkg := range install {
+		if name, version, pinned := v1alpha2.SplitPackagePin(pkg); pinned {
+			pkg = pm.pin(name, version)
+		}
+
+		pkgs = append(pkgs, pkg)
+	}
+
+	return shellescape.QuoteCommand(pkgs)
+}
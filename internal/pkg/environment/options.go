@@ -17,11 +17,20 @@ type Options struct {
 	Target         string
 	CommonPrefix   string
 	ProxyEnv       *llb.ProxyEnv
+
+	// MetaResolver resolves image configs (e.g. buildkit gateway client), used to resolve dependency locations from image labels, optional.
+	MetaResolver llb.ImageMetaResolver
 }
 
 // GetVariables returns set of variables set for options.
+//
+// Platforms are available for templating as `.Platform` (target) and `.BuildPlatform`.
 func (options *Options) GetVariables() types.Variables {
 	return Default().
 		Merge(options.BuildPlatform.BuildVariables()).
-		Merge(options.TargetPlatform.TargetVariables())
+		Merge(options.TargetPlatform.TargetVariables()).
+		Merge(types.Variables{
+			"Platform":      options.TargetPlatform,
+			"BuildPlatform": options.BuildPlatform,
+		})
 }
@@ -0,0 +1,102 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+// Package format provides registry of supported Pkgfile formats.
+package format
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha3"
+)
+
+// Default format is used when Pkgfile is missing.
+const Default = "v1alpha2"
+
+// Format describes a version of `pkg.yaml` schema.
+//
+// Format picks the loader and the validator for `pkg.yaml`. Every format loads `pkg.yaml`
+// into v1alpha2.Pkg, so the graph is built and converted to LLB the same way for all formats.
+type Format struct {
+	// Name is the value of the `format:` field in Pkgfile.
+	Name string
+	// LoadPkg loads and validates single `pkg.yaml`.
+	LoadPkg func(baseDir, fileName string, contents []byte, vars types.Variables, options v1alpha2.LoadOptions) (*v1alpha2.Pkg, error)
+	// Validate performs format-specific checks of the loaded package with the variants of the build tree, optional.
+	Validate func(pkg *v1alpha2.Pkg, variants v1alpha2.Variants) error
+	// StrictTemplates is the default for the Pkgfile `strict-templates` option.
+	StrictTemplates bool
+}
+
+// LoadOptions returns options to load `pkg.yaml` files with the Pkgfile settings.
+//
+// Pkgfile might be nil.
+func (format *Format) LoadOptions(pkgfile *v1alpha2.Pkgfile) v1alpha2.LoadOptions {
+	options := v1alpha2.LoadOptions{
+		StrictTemplates: format.StrictTemplates,
+	}
+
+	if pkgfile != nil && pkgfile.StrictTemplates != nil {
+		options.StrictTemplates = *pkgfile.StrictTemplates
+	}
+
+	return options
+}
+
+var formats = map[string]*Format{}
+
+func register(format *Format) {
+	formats[format.Name] = format
+}
+
+func init() {
+	register(&Format{
+		Name:    "v1alpha2",
+		LoadPkg: v1alpha2.LoadPkg,
+	})
+
+	register(&Format{
+		Name:            "v1alpha3",
+		LoadPkg:         v1alpha3.LoadPkg,
+		Validate:        v1alpha3.Validate,
+		StrictTemplates: true,
+	})
+}
+
+// Supported returns sorted list of supported format names.
+func Supported() []string {
+	names := make([]string, 0, len(formats))
+
+	for name := range formats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Get returns format by name.
+func Get(name string) (*Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %q, supported formats: %q", name, Supported())
+	}
+
+	return format, nil
+}
+
+// ForPkgfile returns format for the Pkgfile.
+//
+// If Pkgfile is missing, Default format is used.
+func ForPkgfile(pkgfile *v1alpha2.Pkgfile) (*Format, error) {
+	if pkgfile == nil {
+		return Get(Default)
+	}
+
+	return Get(pkgfile.Format)
+}
@@ -0,0 +1,99 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package format_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/format"
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestForPkgfile(t *testing.T) {
+	f, err := format.ForPkgfile(nil)
+	require.NoError(t, err)
+	assert.Equal(t, format.Default, f.Name)
+
+	f, err = format.ForPkgfile(&v1alpha2.Pkgfile{Format: "v1alpha3"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1alpha3", f.Name)
+
+	_, err = format.ForPkgfile(&v1alpha2.Pkgfile{Format: "v1alpha1"})
+	assert.EqualError(t, err, `unsupported format: "v1alpha1", supported formats: ["v1alpha2" "v1alpha3"]`)
+}
+
+func TestNewPkgStrict(t *testing.T) {
+	contents := []byte(`name: test
+variant: scratch
+unknown: field
+`)
+
+	v1alpha2Format, err := format.Get("v1alpha2")
+	require.NoError(t, err)
+
+	pkg, err := v1alpha2Format.LoadPkg("test", "test/pkg.yaml", contents, types.Variables{}, v1alpha2.LoadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "test", pkg.Name)
+
+	v1alpha3Format, err := format.Get("v1alpha3")
+	require.NoError(t, err)
+
+	_, err = v1alpha3Format.LoadPkg("test", "test/pkg.yaml", contents, types.Variables{}, v1alpha2.LoadOptions{})
+	assert.Error(t, err)
+}
+
+func TestLoadOptions(t *testing.T) {
+	v1alpha2Format, err := format.Get("v1alpha2")
+	require.NoError(t, err)
+
+	v1alpha3Format, err := format.Get("v1alpha3")
+	require.NoError(t, err)
+
+	assert.False(t, v1alpha2Format.LoadOptions(nil).StrictTemplates)
+	assert.True(t, v1alpha3Format.LoadOptions(nil).StrictTemplates)
+
+	pkgfile, err := v1alpha2.NewPkgfile([]byte(`format: v1alpha3
+strict-templates: false
+`))
+	require.NoError(t, err)
+
+	assert.False(t, v1alpha3Format.LoadOptions(pkgfile).StrictTemplates)
+
+	pkgfile, err = v1alpha2.NewPkgfile([]byte(`format: v1alpha2
+strict-templates: true
+`))
+	require.NoError(t, err)
+
+	assert.True(t, v1alpha2Format.LoadOptions(pkgfile).StrictTemplates)
+}
+
+func TestValidate(t *testing.T) {
+	pkg, err := v1alpha2.NewPkg("test", "test/pkg.yaml", []byte(`name: test
+variant: bare
+dependencies:
+  - stage: base
+`), types.Variables{})
+	require.NoError(t, err)
+
+	variants := v1alpha2.Variants{"bare": {}}
+
+	v1alpha2Format, err := format.Get("v1alpha2")
+	require.NoError(t, err)
+
+	assert.Nil(t, v1alpha2Format.Validate)
+
+	v1alpha3Format, err := format.Get("v1alpha3")
+	require.NoError(t, err)
+
+	err = v1alpha3Format.Validate(pkg, variants)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "set `base` explicitly")
+
+	assert.NoError(t, v1alpha3Format.Validate(pkg, v1alpha2.DefaultVariants()))
+}
@@ -23,11 +23,13 @@ import (
 	"github.com/talos-systems/bldr/internal/pkg/convert"
 	"github.com/talos-systems/bldr/internal/pkg/environment"
 	"github.com/talos-systems/bldr/internal/pkg/solver"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
 )
 
 const (
 	keyTarget         = "target"
 	keyTargetPlatform = "platform"
+	keyBuildPlatform  = "build-platform"
 	keyMultiPlatform  = "multi-platform"
 
 	buildArgPrefix = "build-arg:"
@@ -44,6 +46,7 @@ func Build(ctx context.Context, c client.Client, options *environment.Options) (
 
 	options.Target = opts[keyTarget]
 	options.ProxyEnv = proxyEnvFromBuildArgs(filter(opts, buildArgPrefix))
+	options.MetaResolver = c
 
 	platforms := []environment.Platform{options.TargetPlatform}
 
@@ -61,6 +64,17 @@ func Build(ctx context.Context, c client.Client, options *environment.Options) (
 		}
 	}
 
+	// build platform defaults to the target platform (native build)
+	var buildPlatform *environment.Platform
+
+	if opts[keyBuildPlatform] != "" {
+		buildPlatform = &environment.Platform{}
+
+		if err := buildPlatform.Set(opts[keyBuildPlatform]); err != nil {
+			return nil, fmt.Errorf("unsupported build platform %v: %w", opts[keyBuildPlatform], err)
+		}
+	}
+
 	exportMap := len(platforms) > 1
 
 	if v := opts[keyMultiPlatform]; v != "" {
@@ -93,6 +107,10 @@ func Build(ctx context.Context, c client.Client, options *environment.Options) (
 			options.BuildPlatform = platform
 			options.TargetPlatform = platform
 
+			if buildPlatform != nil {
+				options.BuildPlatform = *buildPlatform
+			}
+
 			if exportMap {
 				options.CommonPrefix = fmt.Sprintf("%s ", platform.ID)
 			}
@@ -103,9 +121,10 @@ func Build(ctx context.Context, c client.Client, options *environment.Options) (
 			}
 
 			loader := solver.BuildkitFrontendLoader{
-				Context: options.GetVariables(),
-				Ref:     pkgRef,
-				Ctx:     ctx,
+				Context:  options.GetVariables(),
+				Ref:      pkgRef,
+				Ctx:      ctx,
+				ReadFile: readLocalFile(ctx, c),
 			}
 
 			packages, err := solver.NewPackages(&loader)
@@ -135,6 +154,16 @@ func Build(ctx context.Context, c client.Client, options *environment.Options) (
 				return err
 			}
 
+			// package metadata labels take precedence over global Pkgfile labels,
+			// bldr labels are always set as they are used to consume the image
+			labels := map[string]string{}
+
+			for _, set := range []map[string]string{packages.ImageLabels(), graph.Root.Pkg.ImageLabels(), graph.Labels()} {
+				for key, value := range set {
+					labels[key] = value
+				}
+			}
+
 			img := dockerfile2llb.Image{
 				Image: specs.Image{
 					Architecture: platform.PlatformSpec.Architecture,
@@ -145,7 +174,7 @@ func Build(ctx context.Context, c client.Client, options *environment.Options) (
 				},
 				Config: dockerfile2llb.ImageConfig{
 					ImageConfig: specs.ImageConfig{
-						Labels: packages.ImageLabels(),
+						Labels: labels,
 					},
 				},
 				Variant: platform.PlatformSpec.Variant,
@@ -190,14 +219,34 @@ func Build(ctx context.Context, c client.Client, options *environment.Options) (
 }
 
 func fetchPkgs(ctx context.Context, c client.Client) (client.Reference, error) {
-	name := fmt.Sprintf("load %s and %ss", constants.Pkgfile, constants.PkgYaml)
+	// Pkgfile is loaded first to get the list of files it includes
+	pkgfileRef, err := fetchLocal(ctx, c, fmt.Sprintf("load %s", constants.Pkgfile), []string{constants.Pkgfile})
+	if err != nil {
+		return nil, err
+	}
 
+	contents, err := pkgfileRef.ReadFile(ctx, client.ReadRequest{
+		Filename: constants.Pkgfile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading %q: %w", constants.Pkgfile, err)
+	}
+
+	pkgFile, err := v1alpha2.NewPkgfile(contents)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %q: %w", constants.Pkgfile, err)
+	}
+
+	return fetchLocal(ctx, c, fmt.Sprintf("load %s and %ss", constants.Pkgfile, constants.PkgYaml), append([]string{
+		constants.Pkgfile,
+		"**/" + constants.PkgYaml,
+		"*/",
+	}, pkgFile.IncludePatterns()...))
+}
+
+func fetchLocal(ctx context.Context, c client.Client, name string, includePatterns []string) (client.Reference, error) {
 	src := llb.Local(localNameDockerfile,
-		llb.IncludePatterns([]string{
-			constants.Pkgfile,
-			"**/" + constants.PkgYaml,
-			"*/",
-		}),
+		llb.IncludePatterns(includePatterns),
 		llb.SessionID(c.BuildOpts().SessionID),
 		llb.SharedKeyHint(sharedKeyHint),
 		llb.WithCustomName(name),
@@ -218,6 +267,20 @@ func fetchPkgs(ctx context.Context, c client.Client) (client.Reference, error) {
 	return res.SingleRef()
 }
 
+// readLocalFile returns a function to read files (e.g. for templates) which are not fetched with fetchPkgs.
+func readLocalFile(ctx context.Context, c client.Client) func(path string) ([]byte, error) {
+	return func(path string) ([]byte, error) {
+		ref, err := fetchLocal(ctx, c, fmt.Sprintf("load %s", path), []string{path})
+		if err != nil {
+			return nil, err
+		}
+
+		return ref.ReadFile(ctx, client.ReadRequest{
+			Filename: path,
+		})
+	}
+}
+
 func proxyEnvFromBuildArgs(args map[string]string) *llb.ProxyEnv {
 	pe := &llb.ProxyEnv{}
 	isNil := true
@@ -10,10 +10,12 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/talos-systems/bldr/internal/pkg/constants"
+	"github.com/talos-systems/bldr/internal/pkg/format"
 	"github.com/talos-systems/bldr/internal/pkg/types"
 	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
 )
@@ -25,9 +27,24 @@ type BuildkitFrontendLoader struct {
 	Ref     client.Reference
 	Ctx     context.Context
 
+	// ReadFile reads files of the build context which are not in Ref (optional),
+	// path is relative to the root of the build context.
+	ReadFile func(path string) ([]byte, error)
+
 	pkgFile *v1alpha2.Pkgfile
 }
 
+// WithContext implements PackageLoader.
+func (bkfl *BuildkitFrontendLoader) WithContext(vars types.Variables) PackageLoader {
+	return &BuildkitFrontendLoader{
+		Logger:   bkfl.Logger,
+		Context:  vars,
+		Ref:      bkfl.Ref,
+		Ctx:      bkfl.Ctx,
+		ReadFile: bkfl.ReadFile,
+	}
+}
+
 type packageProcess func(baseDir string, contents []byte) error
 
 func (bkfl *BuildkitFrontendLoader) walk(path string, process packageProcess) error {
@@ -80,32 +97,66 @@ func (bkfl *BuildkitFrontendLoader) Load() (*LoadResult, error) {
 		return nil, fmt.Errorf("error parsing %q: %w", constants.Pkgfile, err)
 	}
 
+	if err = bkfl.pkgFile.LoadIncludes(bkfl.Context, func(path string) ([]byte, error) {
+		return bkfl.Ref.ReadFile(bkfl.Ctx, client.ReadRequest{
+			Filename: path,
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("error loading includes of %q: %w", constants.Pkgfile, err)
+	}
+
+	pkgFormat, err := format.ForPkgfile(bkfl.pkgFile)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %q: %w", constants.Pkgfile, err)
+	}
+
 	log.Printf("loaded %q", constants.Pkgfile)
 
 	bkfl.Context.Merge(bkfl.pkgFile.Vars)
 
+	var sources []pkgSource
+
+	process := func(baseDir string, contents []byte) error {
+		sources = append(sources, pkgSource{
+			baseDir:  baseDir,
+			fileName: strings.TrimPrefix(filepath.Join(baseDir, constants.PkgYaml), "/"),
+			contents: contents,
+		})
+
+		return nil
+	}
+
+	err = bkfl.walk("/", process)
+
+	options := pkgFormat.LoadOptions(bkfl.pkgFile)
+
+	if bkfl.ReadFile != nil {
+		options.ReadFile = func(path string) ([]byte, error) {
+			return bkfl.ReadFile(strings.TrimPrefix(path, "/"))
+		}
+	}
+
+	loader := newTreeLoader(pkgFormat, bkfl.Context, options, sources)
+
 	var (
 		pkgs     []*v1alpha2.Pkg
 		multiErr *multierror.Error
 	)
 
-	process := func(baseDir string, contents []byte) error {
-		pkg, err2 := v1alpha2.NewPkg(baseDir, "", contents, bkfl.Context)
+	for i, src := range sources {
+		pkg, err2 := loader.load(i)
 		if err2 != nil {
-			log.Printf("error loading %q: %s", baseDir, err2)
-			multiErr = multierror.Append(multiErr, fmt.Errorf("error loading %q: %w", baseDir, err2))
+			log.Printf("error loading %q: %s", src.baseDir, err2)
+			multiErr = multierror.Append(multiErr, fmt.Errorf("error loading %q: %w", src.baseDir, err2))
 
-			return nil
+			continue
 		}
 
-		log.Printf("loaded pkg %q from %q", pkg.Name, baseDir)
-		pkgs = append(pkgs, pkg)
+		log.Printf("loaded pkg %q from %q", pkg.Name, src.baseDir)
 
-		return nil
+		pkgs = append(pkgs, pkg)
 	}
 
-	err = bkfl.walk("/", process)
-
 	return &LoadResult{
 		Pkgfile: bkfl.pkgFile,
 		Pkgs:    pkgs,
@@ -14,6 +14,7 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/talos-systems/bldr/internal/pkg/constants"
+	"github.com/talos-systems/bldr/internal/pkg/format"
 	"github.com/talos-systems/bldr/internal/pkg/types"
 	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
 )
@@ -24,10 +25,14 @@ type FilesystemPackageLoader struct {
 	Root    string
 	Context types.Variables
 
+	// IgnoreConditions keeps all the entries of `pkg.yaml` regardless of `when` conditions.
+	IgnoreConditions bool
+
 	absRootPath string
-	pkgs        []*v1alpha2.Pkg
+	sources     []pkgSource
 	multiErr    *multierror.Error
 	pkgFile     *v1alpha2.Pkgfile
+	pkgFormat   *format.Format
 }
 
 func (fspl *FilesystemPackageLoader) walkFunc() filepath.WalkFunc {
@@ -46,7 +51,7 @@ func (fspl *FilesystemPackageLoader) walkFunc() filepath.WalkFunc {
 		}
 
 		if info.Name() == constants.PkgYaml {
-			pkg, e := fspl.loadPkg(path)
+			src, e := fspl.readPkg(path)
 			if e != nil {
 				fspl.Logger.Printf("error loading %q: %s", path, e)
 				fspl.multiErr = multierror.Append(fspl.multiErr, fmt.Errorf("error loading %q: %w", path, e))
@@ -54,8 +59,7 @@ func (fspl *FilesystemPackageLoader) walkFunc() filepath.WalkFunc {
 				return nil
 			}
 
-			fspl.Logger.Printf("loaded pkg %q from %q", pkg.Name, path)
-			fspl.pkgs = append(fspl.pkgs, pkg)
+			fspl.sources = append(fspl.sources, src)
 		}
 
 		return nil
@@ -83,40 +87,86 @@ func (fspl *FilesystemPackageLoader) Load() (*LoadResult, error) {
 		return nil, err
 	}
 
-	fspl.pkgs = nil
+	fspl.pkgFormat, err = format.ForPkgfile(fspl.pkgFile)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %q: %w", constants.Pkgfile, err)
+	}
+
+	fspl.sources = nil
 
 	err = filepath.Walk(fspl.Root, fspl.walkFunc())
 
+	options := fspl.pkgFormat.LoadOptions(fspl.pkgFile)
+	options.ReadFile = fspl.readFile
+	options.IgnoreConditions = fspl.IgnoreConditions
+
+	loader := newTreeLoader(fspl.pkgFormat, fspl.Context, options, fspl.sources)
+
+	var pkgs []*v1alpha2.Pkg
+
+	for i, src := range fspl.sources {
+		pkg, e := loader.load(i)
+		if e != nil {
+			fspl.Logger.Printf("error loading %q: %s", src.fileName, e)
+			fspl.multiErr = multierror.Append(fspl.multiErr, fmt.Errorf("error loading %q: %w", src.fileName, e))
+
+			continue
+		}
+
+		fspl.Logger.Printf("loaded pkg %q from %q", pkg.Name, src.fileName)
+
+		pkgs = append(pkgs, pkg)
+	}
+
 	return &LoadResult{
 		Pkgfile: fspl.pkgFile,
-		Pkgs:    fspl.pkgs,
+		Pkgs:    pkgs,
 	}, multierror.Append(fspl.multiErr, err).ErrorOrNil()
 }
 
-func (fspl *FilesystemPackageLoader) loadPkg(path string) (*v1alpha2.Pkg, error) {
+// WithContext implements PackageLoader.
+func (fspl *FilesystemPackageLoader) WithContext(vars types.Variables) PackageLoader {
+	return &FilesystemPackageLoader{
+		Logger:           fspl.Logger,
+		Root:             fspl.Root,
+		Context:          vars,
+		IgnoreConditions: fspl.IgnoreConditions,
+	}
+}
+
+func (fspl *FilesystemPackageLoader) readPkg(path string) (pkgSource, error) {
 	absFile, err := filepath.Abs(path)
 	if err != nil {
-		return nil, err
+		return pkgSource{}, err
 	}
 
 	basePath, err := filepath.Rel(fspl.absRootPath, absFile)
 	if err != nil {
-		return nil, err
+		return pkgSource{}, err
 	}
 
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return pkgSource{}, err
 	}
 
 	defer f.Close() //nolint:errcheck
 
 	contents, err := ioutil.ReadAll(f)
 	if err != nil {
-		return nil, err
+		return pkgSource{}, err
 	}
 
-	return v1alpha2.NewPkg(filepath.Dir(basePath), path, contents, fspl.Context)
+	return pkgSource{
+		baseDir:  filepath.Dir(basePath),
+		fileName: path,
+		contents: contents,
+	}, nil
+}
+
+// readFile reads file relative to the root of the build tree.
+func (fspl *FilesystemPackageLoader) readFile(path string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(fspl.Root, filepath.FromSlash(path)))
 }
 
 func (fspl *FilesystemPackageLoader) loadPkgfile() error {
@@ -142,6 +192,10 @@ func (fspl *FilesystemPackageLoader) loadPkgfile() error {
 		return fmt.Errorf("error parsing %q: %w", constants.Pkgfile, err)
 	}
 
+	if err = fspl.pkgFile.LoadIncludes(fspl.Context, fspl.readFile); err != nil {
+		return fmt.Errorf("error loading includes of %q: %w", constants.Pkgfile, err)
+	}
+
 	fspl.Context.Merge(fspl.pkgFile.Vars)
 	fspl.Logger.Printf("loaded %q", constants.Pkgfile)
 
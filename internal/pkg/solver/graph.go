@@ -5,10 +5,15 @@
 package solver
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/emicklei/dot"
 
+	"github.com/talos-systems/bldr/internal/pkg/constants"
+	"github.com/talos-systems/bldr/internal/pkg/types"
 	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
 )
 
@@ -22,7 +27,22 @@ type PackageDependency struct {
 
 // ID returns unique string for dependency.
 func (dep PackageDependency) ID() string {
-	return fmt.Sprintf("%s-%s-%s", dep.Image, dep.Stage, dep.To)
+	return fmt.Sprintf("%s-%s-%v-%s", dep.Image, dep.Stage, dep.Copies(), dep.Platform)
+}
+
+// RuntimeDependencies returns (recursively) all the runtime dependencies pulled in by the dependency.
+//
+// For dependencies on package output, runtime dependencies of the output are returned.
+func (dep PackageDependency) RuntimeDependencies() []PackageDependency {
+	if dep.Node == nil {
+		return nil
+	}
+
+	if output := dep.Output(); output != "" {
+		return runtimeDependencies(dep.Node.Outputs[output])
+	}
+
+	return dep.Node.RuntimeDependencies()
 }
 
 // PackageNode is a Pkg with associated dependencies.
@@ -30,17 +50,46 @@ type PackageNode struct {
 	Pkg          *v1alpha2.Pkg
 	Name         string
 	Dependencies []PackageDependency
+
+	// Base is resolved explicit base of the package, if set.
+	Base *PackageDependency
+
+	// Outputs contains resolved runtime dependencies of package outputs.
+	Outputs map[string][]PackageDependency
 }
 
 // DumpDot dumps node and dependencies.
 func (node *PackageNode) DumpDot(g *dot.Graph) dot.Node {
 	n := g.Node(node.Name)
 
-	for _, dep := range node.Dependencies {
+	dependencies := append([]v1alpha2.Dependency(nil), node.Pkg.Dependencies...)
+
+	outputNames := make([]string, 0, len(node.Pkg.Outputs))
+	for outputName := range node.Pkg.Outputs {
+		outputNames = append(outputNames, outputName)
+	}
+
+	sort.Strings(outputNames)
+
+	for _, outputName := range outputNames {
+		for _, dep := range node.Pkg.Outputs[outputName].Dependencies {
+			// output dependencies are always runtime
+			dep.Runtime = true
+			dependencies = append(dependencies, dep)
+		}
+	}
+
+	if node.Pkg.Base != nil {
+		dependencies = append(dependencies, *node.Pkg.Base)
+	}
+
+	for i, dep := range dependencies {
 		var depNode dot.Node
 
+		isBase := node.Pkg.Base != nil && i == len(dependencies)-1
+
 		if dep.IsInternal() {
-			depNode = g.Node(dep.Stage)
+			depNode = g.Node(dep.StageName())
 		} else {
 			depNode = g.Node(dep.Image)
 			depNode.Box()
@@ -50,14 +99,22 @@ func (node *PackageNode) DumpDot(g *dot.Graph) dot.Node {
 
 		edge := depNode.Edge(n)
 
+		if output := dep.Output(); output != "" {
+			edge.Label(output)
+		}
+
 		if dep.Runtime {
 			edge.Attr("style", "bold")
 			edge.Attr("color", "forestgreen")
 		}
+
+		if isBase {
+			edge.Attr("style", "dashed")
+		}
 	}
 
 	for _, dep := range node.Pkg.Install {
-		packageNode := g.Node("Alpine: " + dep)
+		packageNode := g.Node("Package: " + dep)
 		packageNode.Box()
 		packageNode.Attr("fillcolor", "aquamarine")
 		packageNode.Attr("style", "filled")
@@ -69,16 +126,18 @@ func (node *PackageNode) DumpDot(g *dot.Graph) dot.Node {
 }
 
 // RuntimeDependencies returns (recursively) all the runtime dependencies for the package.
-func (node *PackageNode) RuntimeDependencies() (deps []PackageDependency) {
-	for _, dep := range node.Dependencies {
+func (node *PackageNode) RuntimeDependencies() []PackageDependency {
+	return runtimeDependencies(node.Dependencies)
+}
+
+func runtimeDependencies(dependencies []PackageDependency) (deps []PackageDependency) {
+	for _, dep := range dependencies {
 		if !dep.Runtime {
 			continue
 		}
 
 		deps = append(deps, dep)
-		if dep.Node != nil {
-			deps = append(deps, dep.Node.RuntimeDependencies()...)
-		}
+		deps = append(deps, dep.RuntimeDependencies()...)
 	}
 
 	return
@@ -87,6 +146,108 @@ func (node *PackageNode) RuntimeDependencies() (deps []PackageDependency) {
 // PackageGraph capture root of the DAG.
 type PackageGraph struct {
 	Root *PackageNode
+
+	// Output is the name of the Root output to build, empty for the main package output.
+	Output string
+
+	// Pkgfile might be nil if Pkgfile is missing.
+	Pkgfile *v1alpha2.Pkgfile
+
+	packages *Packages
+	nodes    map[string]*PackageNode
+}
+
+// Node returns the package node by name, resolving it if it's not part of the graph yet.
+func (graph *PackageGraph) Node(name string) (*PackageNode, error) {
+	if node := graph.nodes[name]; node != nil {
+		return node, nil
+	}
+
+	if graph.packages == nil {
+		return nil, fmt.Errorf("package %q not defined", name)
+	}
+
+	if graph.nodes == nil {
+		graph.nodes = make(map[string]*PackageNode)
+	}
+
+	return graph.packages.resolve(name, nil, graph.nodes)
+}
+
+// ForContext returns the graph of the same packages loaded with different variables, e.g. for another platform.
+//
+// Nodes of the returned graph are resolved on demand with Node, Root is not set.
+func (graph *PackageGraph) ForContext(vars types.Variables) (*PackageGraph, error) {
+	if graph.packages == nil {
+		return nil, errors.New("packages can't be reloaded")
+	}
+
+	packages, err := graph.packages.Reload(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PackageGraph{
+		Pkgfile:  packages.pkgfile,
+		packages: packages,
+		nodes:    make(map[string]*PackageNode),
+	}, nil
+}
+
+// Finalize returns finalize instructions of the Root output being built.
+func (graph *PackageGraph) Finalize() []v1alpha2.Finalize {
+	if graph.Output == "" {
+		return graph.Root.Pkg.Finalize
+	}
+
+	return graph.Root.Pkg.Outputs[graph.Output].Finalize
+}
+
+// RuntimeDependencies returns runtime dependencies of the Root output being built.
+func (graph *PackageGraph) RuntimeDependencies() []PackageDependency {
+	if graph.Output == "" {
+		return graph.Root.RuntimeDependencies()
+	}
+
+	return runtimeDependencies(graph.Root.Outputs[graph.Output])
+}
+
+// Labels returns bldr metadata labels for the output image.
+func (graph *PackageGraph) Labels() map[string]string {
+	name := graph.Root.Name
+	if graph.Output != "" {
+		name += v1alpha2.OutputSeparator + graph.Output
+	}
+
+	labels := map[string]string{
+		constants.LabelName: name,
+	}
+
+	if to := v1alpha2.FinalizeLocation(graph.Finalize()); to != "" {
+		labels[constants.LabelTo] = to
+	}
+
+	var runtimeDeps []string
+
+	seen := map[string]struct{}{}
+
+	for _, dep := range graph.RuntimeDependencies() {
+		ref := dep.Image + dep.Stage
+
+		if _, exists := seen[ref]; exists {
+			continue
+		}
+
+		seen[ref] = struct{}{}
+
+		runtimeDeps = append(runtimeDeps, ref)
+	}
+
+	if len(runtimeDeps) > 0 {
+		labels[constants.LabelRuntimeDependencies] = strings.Join(runtimeDeps, ",")
+	}
+
+	return labels
 }
 
 func (graph *PackageGraph) flatten(set PackageSet, node *PackageNode, skip map[*PackageNode]struct{}) PackageSet {
@@ -97,12 +258,24 @@ func (graph *PackageGraph) flatten(set PackageSet, node *PackageNode, skip map[*
 	set = append(set, node)
 	skip[node] = struct{}{}
 
+	if node.Base != nil && node.Base.Node != nil {
+		set = graph.flatten(set, node.Base.Node, skip)
+	}
+
 	for _, dep := range node.Dependencies {
 		if dep.Node != nil {
 			set = graph.flatten(set, dep.Node, skip)
 		}
 	}
 
+	for _, deps := range node.Outputs {
+		for _, dep := range deps {
+			if dep.Node != nil {
+				set = graph.flatten(set, dep.Node, skip)
+			}
+		}
+	}
+
 	return set
 }
 
@@ -5,6 +5,7 @@
 package solver
 
 import (
+	"github.com/talos-systems/bldr/internal/pkg/types"
 	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
 )
 
@@ -17,4 +18,6 @@ type LoadResult struct {
 // PackageLoader implements some way to fetch collection of Pkgs.
 type PackageLoader interface {
 	Load() (*LoadResult, error)
+	// WithContext returns new loader which loads the same packages with different variables.
+	WithContext(vars types.Variables) PackageLoader
 }
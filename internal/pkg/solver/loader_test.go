@@ -0,0 +1,130 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package solver_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+
+	"github.com/talos-systems/bldr/internal/pkg/solver"
+	"github.com/talos-systems/bldr/internal/pkg/types"
+)
+
+// brokenTree has a YAML error on the line 6 of `pkg.yaml`, which is rendered as line 5.
+var brokenTree = map[string]string{
+	"Pkgfile": "format: v1alpha2\n",
+	"broken/pkg.yaml": `# modules
+{{- range .MODULES }}
+# {{ . }}
+{{- end }}
+name: broken
+  steps: []
+`,
+}
+
+func treeVars() types.Variables {
+	return types.Variables{
+		"MODULES": []interface{}{"zlib", "openssl"},
+	}
+}
+
+// fakeRef serves files of the build context from memory.
+type fakeRef struct {
+	files map[string]string
+}
+
+func (ref *fakeRef) ToState() (llb.State, error) {
+	return llb.Scratch(), nil
+}
+
+func (ref *fakeRef) ReadFile(_ context.Context, req client.ReadRequest) ([]byte, error) {
+	contents, ok := ref.files[strings.TrimPrefix(req.Filename, "/")]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return []byte(contents), nil
+}
+
+func (ref *fakeRef) StatFile(context.Context, client.StatRequest) (*fstypes.Stat, error) {
+	return nil, os.ErrNotExist
+}
+
+func (ref *fakeRef) ReadDir(_ context.Context, req client.ReadDirRequest) ([]*fstypes.Stat, error) {
+	dir := strings.Trim(req.Path, "/")
+	seen := map[string]bool{}
+
+	var entries []*fstypes.Stat
+
+	for name := range ref.files {
+		rel := name
+
+		if dir != "" {
+			if !strings.HasPrefix(name, dir+"/") {
+				continue
+			}
+
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+
+		entry := strings.SplitN(rel, "/", 2)
+		if seen[entry[0]] {
+			continue
+		}
+
+		seen[entry[0]] = true
+
+		mode := uint32(0o644)
+		if len(entry) > 1 {
+			mode = uint32(os.ModeDir | 0o755)
+		}
+
+		entries = append(entries, &fstypes.Stat{Path: entry[0], Mode: mode})
+	}
+
+	return entries, nil
+}
+
+func TestFilesystemLoaderErrorPositions(t *testing.T) {
+	root := t.TempDir()
+
+	for name, contents := range brokenTree {
+		p := filepath.Join(root, name)
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, ioutil.WriteFile(p, []byte(contents), 0o644))
+	}
+
+	_, err := (&solver.FilesystemPackageLoader{
+		Logger:  log.New(ioutil.Discard, "", 0),
+		Root:    root,
+		Context: treeVars(),
+	}).Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), path.Join(filepath.ToSlash(root), "broken/pkg.yaml")+":6:3:")
+}
+
+func TestBuildkitLoaderErrorPositions(t *testing.T) {
+	_, err := (&solver.BuildkitFrontendLoader{
+		Logger:  log.New(ioutil.Discard, "", 0),
+		Context: treeVars(),
+		Ref:     &fakeRef{files: brokenTree},
+		Ctx:     context.Background(),
+	}).Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken/pkg.yaml:6:3:")
+}
@@ -6,7 +6,12 @@ package solver
 
 import (
 	"fmt"
+	"log"
 
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/talos-systems/bldr/internal/pkg/format"
+	"github.com/talos-systems/bldr/internal/pkg/types"
 	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
 )
 
@@ -14,6 +19,7 @@ import (
 type Packages struct {
 	packages map[string]*v1alpha2.Pkg
 	pkgfile  *v1alpha2.Pkgfile
+	loader   PackageLoader
 }
 
 // NewPackages builds Packages using PackageLoader.
@@ -26,8 +32,16 @@ func NewPackages(loader PackageLoader) (*Packages, error) {
 	result := &Packages{
 		packages: make(map[string]*v1alpha2.Pkg, len(loadResult.Pkgs)),
 		pkgfile:  loadResult.Pkgfile,
+		loader:   loader,
+	}
+
+	pkgFormat, err := format.ForPkgfile(loadResult.Pkgfile)
+	if err != nil {
+		return nil, err
 	}
 
+	variants := loadResult.Pkgfile.AllVariants()
+
 	for _, pkg := range loadResult.Pkgs {
 		name := pkg.Name
 
@@ -35,12 +49,64 @@ func NewPackages(loader PackageLoader) (*Packages, error) {
 			return nil, fmt.Errorf("package %q already exists, duplicate in dirs %q and %q", name, pkg.BaseDir, dup.BaseDir)
 		}
 
+		if err = validateVariant(pkg, variants); err != nil {
+			return nil, err
+		}
+
+		if pkgFormat.Validate != nil {
+			if err = pkgFormat.Validate(pkg, variants); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, warning := range pkg.Warnings(variants) {
+			log.Printf("warning: %s", warning)
+		}
+
+		if err = validateNetwork(pkg, loadResult.Pkgfile); err != nil {
+			return nil, err
+		}
+
 		result.packages[name] = pkg
 	}
 
 	return result, nil
 }
 
+func validateVariant(pkg *v1alpha2.Pkg, variants v1alpha2.Variants) error {
+	spec, ok := variants[pkg.Variant]
+	if !ok {
+		return fmt.Errorf("package %q: unknown variant %q", pkg.Name, pkg.Variant)
+	}
+
+	if len(pkg.Install) > 0 && spec.PackageManager == v1alpha2.PackageManagerNone {
+		return fmt.Errorf("package %q: variant %q doesn't have package manager to install %q", pkg.Name, pkg.Variant, pkg.Install)
+	}
+
+	return nil
+}
+
+// validateNetwork checks that network access is not requested explicitly in the hermetic build.
+func validateNetwork(pkg *v1alpha2.Pkg, pkgfile *v1alpha2.Pkgfile) error {
+	if pkgfile == nil || !pkgfile.Hermetic {
+		return nil
+	}
+
+	var multiErr *multierror.Error
+
+	if pkg.Network != v1alpha2.NetworkDefault && pkg.Network != v1alpha2.NetworkNone {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("package %q: network %q conflicts with hermetic build", pkg.Name, pkg.Network))
+	}
+
+	for _, step := range pkg.Steps {
+		if step.Network != v1alpha2.NetworkDefault && step.Network != v1alpha2.NetworkNone {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("package %q: step %s: network %q conflicts with hermetic build", pkg.Name, step.DisplayName(), step.Network))
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
 func (pkgs *Packages) resolve(name string, path []string, cache map[string]*PackageNode) (*PackageNode, error) {
 	if node := cache[name]; node != nil {
 		return node, nil
@@ -64,36 +130,105 @@ func (pkgs *Packages) resolve(name string, path []string, cache map[string]*Pack
 		Name: name,
 	}
 
-	for _, dep := range pkg.Dependencies {
+	var err error
+
+	if pkg.Base != nil {
+		var base []PackageDependency
+
+		if base, err = pkgs.resolveDependencies(name, v1alpha2.Dependencies{*pkg.Base}, path, cache); err != nil {
+			return nil, err
+		}
+
+		node.Base = &base[0]
+	}
+
+	if node.Dependencies, err = pkgs.resolveDependencies(name, pkg.Dependencies, path, cache); err != nil {
+		return nil, err
+	}
+
+	for outputName, output := range pkg.Outputs {
+		deps := make(v1alpha2.Dependencies, len(output.Dependencies))
+
+		for i, dep := range output.Dependencies {
+			// output dependencies are not used in the build, they are pulled in with the output
+			dep.Runtime = true
+			deps[i] = dep
+		}
+
+		if node.Outputs == nil {
+			node.Outputs = make(map[string][]PackageDependency, len(pkg.Outputs))
+		}
+
+		if node.Outputs[outputName], err = pkgs.resolveDependencies(name+v1alpha2.OutputSeparator+outputName, deps, path, cache); err != nil {
+			return nil, err
+		}
+	}
+
+	cache[name] = node
+
+	return node, nil
+}
+
+func (pkgs *Packages) resolveDependencies(name string, deps v1alpha2.Dependencies, path []string, cache map[string]*PackageNode) ([]PackageDependency, error) {
+	result := make([]PackageDependency, 0, len(deps))
+
+	for _, dep := range deps {
 		nodeDep := PackageDependency{
 			Dependency: dep,
 		}
 
 		if dep.IsInternal() {
-			depPkg, err := pkgs.resolve(dep.Stage, path, cache)
+			depPkg, err := pkgs.resolve(dep.StageName(), path, cache)
 			if err != nil {
 				return nil, fmt.Errorf("error resolving dependency %q of %q: %w", dep.Stage, name, err)
 			}
 
+			if output := dep.Output(); output != "" {
+				if _, exists := depPkg.Pkg.Outputs[output]; !exists {
+					return nil, fmt.Errorf("error resolving dependency %q of %q: package %q has no output %q", dep.Stage, name, depPkg.Name, output)
+				}
+			}
+
 			nodeDep.Node = depPkg
 		}
 
-		node.Dependencies = append(node.Dependencies, nodeDep)
+		result = append(result, nodeDep)
 	}
 
-	cache[name] = node
-
-	return node, nil
+	return result, nil
 }
 
 // Resolve trims down the package tree to have only deps of the target.
+//
+// Target might reference package output as `<name>/<output>`.
 func (pkgs *Packages) Resolve(target string) (*PackageGraph, error) {
-	root, err := pkgs.resolve(target, nil, make(map[string]*PackageNode))
+	name, output := v1alpha2.SplitStage(target)
+
+	cache := make(map[string]*PackageNode)
+
+	root, err := pkgs.resolve(name, nil, cache)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PackageGraph{root}, nil
+	if output != "" {
+		if _, exists := root.Pkg.Outputs[output]; !exists {
+			return nil, fmt.Errorf("package %q has no output %q", name, output)
+		}
+	}
+
+	return &PackageGraph{
+		Root:     root,
+		Output:   output,
+		Pkgfile:  pkgs.pkgfile,
+		packages: pkgs,
+		nodes:    cache,
+	}, nil
+}
+
+// Reload loads the packages again with different variables, e.g. for another platform.
+func (pkgs *Packages) Reload(vars types.Variables) (*Packages, error) {
+	return NewPackages(pkgs.loader.WithContext(vars))
 }
 
 // ToSet converts to set of package nodes.
@@ -118,3 +253,17 @@ func (pkgs *Packages) ToSet() (set PackageSet) {
 func (pkgs *Packages) ImageLabels() map[string]string {
 	return pkgs.pkgfile.Labels
 }
+
+// Variants returns all base image variants (built-in and defined in Pkgfile).
+func (pkgs *Packages) Variants() v1alpha2.Variants {
+	return pkgs.pkgfile.AllVariants()
+}
+
+// Mirrors returns source mirrors defined in Pkgfile.
+func (pkgs *Packages) Mirrors() v1alpha2.Mirrors {
+	if pkgs.pkgfile == nil {
+		return nil
+	}
+
+	return pkgs.pkgfile.Mirrors
+}
@@ -0,0 +1,88 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package solver_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/solver"
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+type staticLoader solver.LoadResult
+
+func (loader *staticLoader) Load() (*solver.LoadResult, error) {
+	return (*solver.LoadResult)(loader), nil
+}
+
+func (loader *staticLoader) WithContext(types.Variables) solver.PackageLoader {
+	return loader
+}
+
+func TestNewPackagesHermetic(t *testing.T) {
+	pkgfile := &v1alpha2.Pkgfile{Format: "v1alpha2", Hermetic: true}
+
+	_, err := solver.NewPackages(&staticLoader{
+		Pkgfile: pkgfile,
+		Pkgs: []*v1alpha2.Pkg{
+			{
+				Name:    "offline",
+				Variant: v1alpha2.Alpine,
+				Network: v1alpha2.NetworkNone,
+				Steps:   v1alpha2.Steps{{}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = solver.NewPackages(&staticLoader{
+		Pkgfile: pkgfile,
+		Pkgs: []*v1alpha2.Pkg{
+			{
+				Name:    "online",
+				Variant: v1alpha2.Alpine,
+				Network: v1alpha2.NetworkSandbox,
+				Steps:   v1alpha2.Steps{{Name: "fetch", Network: v1alpha2.NetworkHost}, {Index: 3, Network: v1alpha2.NetworkHost}},
+			},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `package "online": network "sandbox" conflicts with hermetic build`)
+	assert.Contains(t, err.Error(), `package "online": step fetch: network "host" conflicts with hermetic build`)
+	assert.Contains(t, err.Error(), `package "online": step 3: network "host" conflicts with hermetic build`)
+}
+
+func TestNewPackagesPromotedBase(t *testing.T) {
+	pkgs := []*v1alpha2.Pkg{
+		{
+			Name:         "final",
+			Variant:      "bare",
+			Dependencies: v1alpha2.Dependencies{{Stage: "rootfs"}},
+		},
+		{
+			Name:    "rootfs",
+			Variant: v1alpha2.Alpine,
+		},
+	}
+
+	variants := v1alpha2.Variants{"bare": {}}
+
+	_, err := solver.NewPackages(&staticLoader{
+		Pkgfile: &v1alpha2.Pkgfile{Format: "v1alpha2", Variants: variants},
+		Pkgs:    pkgs,
+	})
+	require.NoError(t, err)
+
+	_, err = solver.NewPackages(&staticLoader{
+		Pkgfile: &v1alpha2.Pkgfile{Format: "v1alpha3", Variants: variants},
+		Pkgs:    pkgs,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `package "final": promoting the first dependency "rootfs" to the base is deprecated`)
+}
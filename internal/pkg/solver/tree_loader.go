@@ -0,0 +1,92 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package solver
+
+import (
+	"fmt"
+
+	"github.com/talos-systems/bldr/internal/pkg/format"
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+// pkgSource is a `pkg.yaml` file found in the build tree.
+type pkgSource struct {
+	baseDir  string
+	fileName string
+	contents []byte
+}
+
+type loadState int
+
+const (
+	notLoaded loadState = iota
+	loading
+	loaded
+)
+
+// treeLoader loads `pkg.yaml` files of the build tree.
+//
+// Packages are loaded lazily, so that `pkg` template function might reference
+// packages regardless of the order they were found in.
+type treeLoader struct {
+	format  *format.Format
+	vars    types.Variables
+	options v1alpha2.LoadOptions
+
+	sources []pkgSource
+	pkgs    []*v1alpha2.Pkg
+	errs    []error
+	states  []loadState
+}
+
+func newTreeLoader(pkgFormat *format.Format, vars types.Variables, options v1alpha2.LoadOptions, sources []pkgSource) *treeLoader {
+	tl := &treeLoader{
+		format:  pkgFormat,
+		vars:    vars,
+		options: options,
+		sources: sources,
+		pkgs:    make([]*v1alpha2.Pkg, len(sources)),
+		errs:    make([]error, len(sources)),
+		states:  make([]loadState, len(sources)),
+	}
+
+	tl.options.PackageMetadata = tl.metadata
+
+	return tl
+}
+
+// load the package by index in sources.
+func (tl *treeLoader) load(i int) (*v1alpha2.Pkg, error) {
+	if tl.states[i] != notLoaded {
+		return tl.pkgs[i], tl.errs[i]
+	}
+
+	tl.states[i] = loading
+
+	src := tl.sources[i]
+
+	tl.pkgs[i], tl.errs[i] = tl.format.LoadPkg(src.baseDir, src.fileName, src.contents, tl.vars, tl.options)
+	tl.states[i] = loaded
+
+	return tl.pkgs[i], tl.errs[i]
+}
+
+// metadata implements `pkg` template function.
+func (tl *treeLoader) metadata(name string) (*v1alpha2.Metadata, error) {
+	for i := range tl.sources {
+		if tl.states[i] == loading {
+			// package which is being loaded (circular reference)
+			continue
+		}
+
+		pkg, err := tl.load(i)
+		if err == nil && pkg.Name == name {
+			return &pkg.Metadata, nil
+		}
+	}
+
+	return nil, fmt.Errorf("package %q not found (or has circular reference)", name)
+}
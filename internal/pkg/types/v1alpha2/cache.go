@@ -0,0 +1,72 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Cache sharing modes.
+const (
+	CacheSharingShared  = "shared"
+	CacheSharingPrivate = "private"
+	CacheSharingLocked  = "locked"
+)
+
+// CacheMounts is a collection of CacheMount.
+type CacheMounts []CacheMount
+
+// Validate cache mounts.
+func (mounts CacheMounts) Validate() error {
+	var multiErr *multierror.Error
+
+	for _, mount := range mounts {
+		multiErr = multierror.Append(multiErr, mount.Validate())
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// CacheMount describes persistent cache directory mounted into step instructions.
+//
+// Contents of the cache directory are not part of the build result.
+type CacheMount struct {
+	Target  string `yaml:"target,omitempty"`
+	ID      string `yaml:"id,omitempty"`
+	Sharing string `yaml:"sharing,omitempty"`
+}
+
+// CacheID returns cache ID, defaults to the target path.
+func (mount *CacheMount) CacheID() string {
+	if mount.ID != "" {
+		return mount.ID
+	}
+
+	return mount.Target
+}
+
+// Validate cache mount.
+func (mount *CacheMount) Validate() error {
+	var multiErr *multierror.Error
+
+	if mount.Target == "" {
+		multiErr = multierror.Append(multiErr, errors.New("cache.target can't be empty"))
+	} else if !filepath.IsAbs(mount.Target) {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("cache.target %q should be absolute path", mount.Target))
+	}
+
+	switch mount.Sharing {
+	case "", CacheSharingShared, CacheSharingPrivate, CacheSharingLocked:
+		// nothing
+	default:
+		multiErr = multierror.Append(multiErr, fmt.Errorf("unknown cache.sharing %q", mount.Sharing))
+	}
+
+	return multiErr.ErrorOrNil()
+}
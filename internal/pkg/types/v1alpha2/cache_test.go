@@ -0,0 +1,53 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestCacheMountCacheID(t *testing.T) {
+	mount := v1alpha2.CacheMount{Target: "/root/.cache/go-build"}
+	assert.Equal(t, "/root/.cache/go-build", mount.CacheID())
+
+	mount.ID = "go-build"
+	assert.Equal(t, "go-build", mount.CacheID())
+}
+
+func TestCacheMountValidate(t *testing.T) {
+	for _, tc := range []struct {
+		mount    v1alpha2.CacheMount
+		expected string
+	}{
+		{
+			mount: v1alpha2.CacheMount{Target: "/root/.cache", Sharing: v1alpha2.CacheSharingLocked},
+		},
+		{
+			mount:    v1alpha2.CacheMount{Sharing: v1alpha2.CacheSharingPrivate},
+			expected: "cache.target can't be empty",
+		},
+		{
+			mount:    v1alpha2.CacheMount{Target: "root/.cache"},
+			expected: `cache.target "root/.cache" should be absolute path`,
+		},
+		{
+			mount:    v1alpha2.CacheMount{Target: "/root/.cache", Sharing: "exclusive"},
+			expected: `unknown cache.sharing "exclusive"`,
+		},
+	} {
+		err := tc.mount.Validate()
+
+		if tc.expected == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/talos-systems/bldr/internal/pkg/types"
+)
+
+// Condition enables an entry only when it evaluates to true.
+//
+// Condition is a Go template pipeline, e.g. `eq .ARCH "aarch64"`,
+// it is evaluated against the same set of variables as `pkg.yaml` template.
+// Empty condition is always true.
+type Condition string
+
+func (cond Condition) template(strict bool) (*template.Template, error) {
+	tmpl := template.New("when").
+		Funcs(funcMap())
+
+	if strict {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+
+	return tmpl.Parse("{{ if " + string(cond) + " }}true{{ end }}")
+}
+
+// Validate condition syntax.
+func (cond Condition) Validate() error {
+	if cond == "" {
+		return nil
+	}
+
+	if _, err := cond.template(false); err != nil {
+		return fmt.Errorf("error parsing when %q: %w", cond, err)
+	}
+
+	return nil
+}
+
+// Evaluate the condition.
+//
+// If strict is set, references to undefined variables are errors (same as `strict-templates`).
+func (cond Condition) Evaluate(vars types.Variables, strict bool) (bool, error) {
+	if cond == "" {
+		return true, nil
+	}
+
+	tmpl, err := cond.template(strict)
+	if err != nil {
+		return false, fmt.Errorf("error parsing when %q: %w", cond, err)
+	}
+
+	var buf bytes.Buffer
+
+	if err = tmpl.Execute(&buf, vars); err != nil {
+		return false, fmt.Errorf("error evaluating when %q: %w", cond, err)
+	}
+
+	return buf.String() == "true", nil
+}
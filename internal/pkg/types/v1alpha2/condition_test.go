@@ -0,0 +1,152 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	vars := types.Variables{
+		"ARCH":       "aarch64",
+		"WITH_TESTS": "1",
+	}
+
+	for _, tt := range []struct {
+		cond     v1alpha2.Condition
+		expected bool
+	}{
+		{"", true},
+		{`eq .ARCH "aarch64"`, true},
+		{`eq .ARCH "x86_64"`, false},
+		{`and (ne .ARCH "armv7") (eq .WITH_TESTS "1")`, true},
+		{`.UNDEFINED`, false},
+	} {
+		tt := tt
+
+		t.Run(string(tt.cond), func(t *testing.T) {
+			require.NoError(t, tt.cond.Validate())
+
+			actual, err := tt.cond.Evaluate(vars, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+
+	assert.Error(t, v1alpha2.Condition(`undefined .ARCH`).Validate())
+	assert.Error(t, v1alpha2.Condition(`eq (`).Validate())
+}
+
+func TestPkgConditions(t *testing.T) {
+	contents := []byte(`name: test
+dependencies:
+  - stage: base
+  - stage: qemu
+    when: ne .ARCH "x86_64"
+steps:
+  - name: arm
+    when: eq .ARCH "aarch64"
+  - name: always
+finalize:
+  - from: /
+    to: /
+  - from: /usr/share/doc
+    to: /doc
+    when: eq .WITH_DOCS "1"
+`)
+
+	pkg, err := v1alpha2.NewPkg("test", "pkg.yaml", contents, types.Variables{
+		"ARCH": "x86_64",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, pkg.Dependencies, 1)
+	assert.Equal(t, "base", pkg.Dependencies[0].Stage)
+
+	require.Len(t, pkg.Steps, 1)
+	assert.Equal(t, "always", pkg.Steps[0].Name)
+	assert.Equal(t, "/tmp/build/1", pkg.Steps[0].TmpDir)
+
+	require.Len(t, pkg.Finalize, 1)
+	assert.Equal(t, "/", pkg.Finalize[0].From)
+}
+
+func TestPkgConditionsFinalize(t *testing.T) {
+	contents := []byte(`name: test
+steps:
+  - build:
+      - make
+finalize:
+  - from: /rootfs
+    to: /
+    when: eq .ARCH "aarch64"
+outputs:
+  lib:
+    finalize:
+      - from: /rootfs/lib
+        to: /lib
+        when: eq .ARCH "aarch64"
+`)
+
+	_, err := v1alpha2.NewPkg("test", "test/pkg.yaml", contents, types.Variables{"ARCH": "x86_64"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "finalize steps are missing after applying `when` conditions")
+	assert.Contains(t, err.Error(), `output "lib": finalize steps are missing after applying`)
+
+	pkg, err := v1alpha2.NewPkg("test", "test/pkg.yaml", contents, types.Variables{"ARCH": "aarch64"})
+	require.NoError(t, err)
+	assert.Len(t, pkg.Finalize, 1)
+}
+
+func TestPkgIgnoreConditions(t *testing.T) {
+	contents := []byte(`name: test
+steps:
+  - when: eq .ARCH "aarch64"
+    patches:
+      - file: patches/arm.patch
+finalize:
+  - from: /
+`)
+
+	pkg, err := v1alpha2.LoadPkg("test", "test/pkg.yaml", contents, types.Variables{"ARCH": "x86_64"}, v1alpha2.LoadOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, pkg.Steps)
+
+	pkg, err = v1alpha2.LoadPkg("test", "test/pkg.yaml", contents, types.Variables{"ARCH": "x86_64"}, v1alpha2.LoadOptions{IgnoreConditions: true})
+	require.NoError(t, err)
+	require.Len(t, pkg.Steps, 1)
+	assert.Equal(t, "patches/arm.patch", pkg.Steps[0].Patches[0].File)
+}
+
+func TestPkgConditionsStrict(t *testing.T) {
+	contents := []byte(`name: test
+steps:
+  - name: arm
+    when: eq .ARHC "aarch64"
+finalize:
+  - from: /
+`)
+	vars := types.Variables{"ARCH": "aarch64"}
+
+	pkg, err := v1alpha2.LoadPkg("test", "test/pkg.yaml", contents, vars, v1alpha2.LoadOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, pkg.Steps)
+
+	_, err = v1alpha2.LoadPkg("test", "test/pkg.yaml", contents, vars, v1alpha2.LoadOptions{StrictTemplates: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "test/pkg.yaml:")
+	assert.Contains(t, err.Error(), `error evaluating when "eq .ARHC \"aarch64\""`)
+	assert.Contains(t, err.Error(), `"ARHC"`)
+
+	ok, err := v1alpha2.Condition(`hasKey . "ARHC"`).Evaluate(vars, true)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
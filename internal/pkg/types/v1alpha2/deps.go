@@ -6,16 +6,24 @@ package v1alpha2
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 )
 
 // Dependency on another image or stage.
 type Dependency struct {
-	Image   string `yaml:"image,omitempty"`
-	Stage   string `yaml:"stage,omitempty"`
-	To      string `yaml:"to,omitempty"`
-	Runtime bool   `yaml:"runtime,omitempty"`
+	Image   string     `yaml:"image,omitempty"`
+	Stage   string     `yaml:"stage,omitempty"`
+	From    string     `yaml:"from,omitempty"`
+	To      string     `yaml:"to,omitempty"`
+	Paths   []CopyPath `yaml:"paths,omitempty"`
+	Runtime bool       `yaml:"runtime,omitempty"`
+
+	Platform DependencyPlatform `yaml:"platform,omitempty"`
+
+	When Condition `yaml:"when,omitempty"`
 }
 
 // IsInternal checks whether dependency is internal to some stage.
@@ -23,8 +31,65 @@ func (d *Dependency) IsInternal() bool {
 	return d.Stage != ""
 }
 
+// StageName returns name of the package referenced by the internal dependency.
+func (d *Dependency) StageName() string {
+	name, _ := SplitStage(d.Stage)
+
+	return name
+}
+
+// Output returns name of the package output referenced by the internal dependency,
+// empty string means main package output.
+func (d *Dependency) Output() string {
+	_, output := SplitStage(d.Stage)
+
+	return output
+}
+
+// SplitStage splits stage reference into package name and output name.
+func SplitStage(stage string) (name, output string) {
+	if idx := strings.Index(stage, OutputSeparator); idx != -1 {
+		return stage[:idx], stage[idx+1:]
+	}
+
+	return stage, ""
+}
+
+// DependencyPlatform overrides the platform dependency is built for.
+type DependencyPlatform string
+
+// Dependency platforms.
+const (
+	// PlatformDefault builds dependency for the same platforms as the package.
+	PlatformDefault DependencyPlatform = ""
+	// PlatformBuild builds dependency for the build platform (e.g. host tools).
+	PlatformBuild DependencyPlatform = "build"
+	// PlatformTarget builds dependency natively for the target platform (e.g. target libraries).
+	PlatformTarget DependencyPlatform = "target"
+)
+
+// Validate dependency platform.
+func (p DependencyPlatform) Validate() error {
+	switch p {
+	case PlatformDefault, PlatformBuild, PlatformTarget:
+		return nil
+	default:
+		return fmt.Errorf("unknown dependency platform %q", p)
+	}
+}
+
+// CopyPath is a pair of paths to copy from the dependency.
+type CopyPath struct {
+	From string `yaml:"from,omitempty"`
+	To   string `yaml:"to,omitempty"`
+}
+
 // Src returns copy source (from dependency).
 func (d *Dependency) Src() string {
+	if d.From != "" {
+		return d.From
+	}
+
 	return "/"
 }
 
@@ -37,6 +102,36 @@ func (d *Dependency) Dest() string {
 	return "/"
 }
 
+// Copies returns list of paths to copy from the dependency.
+//
+// If `paths` is not set, single pair of `from` and `to` is returned.
+func (d *Dependency) Copies() []CopyPath {
+	if len(d.Paths) > 0 {
+		copies := make([]CopyPath, 0, len(d.Paths))
+
+		for _, p := range d.Paths {
+			if p.From == "" {
+				p.From = "/"
+			}
+
+			if p.To == "" {
+				p.To = "/"
+			}
+
+			copies = append(copies, p)
+		}
+
+		return copies
+	}
+
+	return []CopyPath{
+		{
+			From: d.Src(),
+			To:   d.Dest(),
+		},
+	}
+}
+
 // Validate the dependency.
 func (d *Dependency) Validate() error {
 	if d.Image != "" && d.Stage != "" {
@@ -47,7 +142,21 @@ func (d *Dependency) Validate() error {
 		return fmt.Errorf("either image or stage should be set for the dependency")
 	}
 
-	return nil
+	if err := d.Platform.Validate(); err != nil {
+		return err
+	}
+
+	if len(d.Paths) > 0 && (d.From != "" || d.To != "") {
+		return fmt.Errorf("dependency %q can't have both paths and from/to set", d.Image+d.Stage)
+	}
+
+	for _, p := range append([]CopyPath{{From: d.From, To: d.To}}, d.Paths...) {
+		if (p.From != "" && !filepath.IsAbs(p.From)) || (p.To != "" && !filepath.IsAbs(p.To)) {
+			return fmt.Errorf("dependency paths should be absolute: %q -> %q", p.From, p.To)
+		}
+	}
+
+	return d.When.Validate()
 }
 
 // Dependencies is a list of Depency.
@@ -57,8 +166,8 @@ type Dependencies []Dependency
 func (deps Dependencies) Validate() error {
 	var multiErr *multierror.Error
 
-	for _, dep := range deps {
-		multiErr = multierror.Append(multiErr, dep.Validate())
+	for i, dep := range deps {
+		multiErr = multierror.Append(multiErr, entryError("dependencies", i, dep.Validate()))
 	}
 
 	return multiErr.ErrorOrNil()
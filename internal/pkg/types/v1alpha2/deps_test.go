@@ -0,0 +1,63 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestDependencyCopies(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		dep      v1alpha2.Dependency
+		expected []v1alpha2.CopyPath
+	}{
+		{
+			name:     "default",
+			dep:      v1alpha2.Dependency{Stage: "gcc"},
+			expected: []v1alpha2.CopyPath{{From: "/", To: "/"}},
+		},
+		{
+			name:     "from",
+			dep:      v1alpha2.Dependency{Stage: "gcc", From: "/toolchain/lib", To: "/lib"},
+			expected: []v1alpha2.CopyPath{{From: "/toolchain/lib", To: "/lib"}},
+		},
+		{
+			name: "paths",
+			dep: v1alpha2.Dependency{
+				Image: "ghcr.io/talos-systems/tools:v0.3.0",
+				Paths: []v1alpha2.CopyPath{
+					{From: "/toolchain/lib", To: "/lib"},
+					{From: "/toolchain/bin"},
+				},
+			},
+			expected: []v1alpha2.CopyPath{
+				{From: "/toolchain/lib", To: "/lib"},
+				{From: "/toolchain/bin", To: "/"},
+			},
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, tt.dep.Validate())
+			assert.Equal(t, tt.expected, tt.dep.Copies())
+		})
+	}
+
+	assert.Error(t, (&v1alpha2.Dependency{Stage: "gcc", From: "/a", Paths: []v1alpha2.CopyPath{{From: "/b"}}}).Validate())
+	assert.Error(t, (&v1alpha2.Dependency{Stage: "gcc", From: "toolchain"}).Validate())
+	assert.Error(t, (&v1alpha2.Dependency{Stage: "gcc", Paths: []v1alpha2.CopyPath{{To: "lib"}}}).Validate())
+}
+
+func TestDependencyPlatform(t *testing.T) {
+	assert.NoError(t, (&v1alpha2.Dependency{Stage: "gcc", Platform: v1alpha2.PlatformBuild}).Validate())
+	assert.NoError(t, (&v1alpha2.Dependency{Image: "alpine", Platform: v1alpha2.PlatformTarget}).Validate())
+	assert.Error(t, (&v1alpha2.Dependency{Stage: "gcc", Platform: "host"}).Validate())
+}
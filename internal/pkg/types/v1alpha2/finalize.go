@@ -0,0 +1,108 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Symlinks handling modes for finalize.
+const (
+	SymlinksFollow   = "follow"
+	SymlinksPreserve = "preserve"
+)
+
+var ownerRegexp = regexp.MustCompile(`^[0-9]+(:[0-9]+)?$`)
+
+// Finalize is a set of COPY instructions to finalize the build.
+type Finalize struct {
+	From string    `yaml:"from,omitempty"`
+	To   string    `yaml:"to,omitempty"`
+	When Condition `yaml:"when,omitempty"`
+
+	Include  []string `yaml:"include,omitempty"`
+	Exclude  []string `yaml:"exclude,omitempty"`
+	Mode     string   `yaml:"mode,omitempty"`
+	Owner    string   `yaml:"owner,omitempty"`
+	Symlinks string   `yaml:"symlinks,omitempty"`
+}
+
+// FileMode returns parsed mode, or nil if mode is not set.
+func (fin *Finalize) FileMode() (*os.FileMode, error) {
+	if fin.Mode == "" {
+		return nil, nil
+	}
+
+	mode, err := strconv.ParseUint(fin.Mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing finalize.mode %q: %w", fin.Mode, err)
+	}
+
+	fileMode := os.FileMode(mode)
+
+	return &fileMode, nil
+}
+
+// FollowSymlinks returns true if symlink in `from` should be resolved.
+func (fin *Finalize) FollowSymlinks() bool {
+	return fin.Symlinks != SymlinksPreserve
+}
+
+// Validate finalize instruction.
+func (fin *Finalize) Validate() error {
+	var multiErr *multierror.Error
+
+	multiErr = multierror.Append(multiErr, fin.When.Validate())
+
+	for _, pattern := range append(append([]string(nil), fin.Include...), fin.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("error parsing finalize pattern %q: %w", pattern, err))
+		}
+	}
+
+	if mode, err := fin.FileMode(); err != nil {
+		multiErr = multierror.Append(multiErr, err)
+	} else if mode != nil && *mode&^os.ModePerm != 0 {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("finalize.mode %q should contain only permission bits", fin.Mode))
+	}
+
+	if fin.Owner != "" && !ownerRegexp.MatchString(fin.Owner) {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("finalize.owner %q should be numeric uid[:gid]", fin.Owner))
+	}
+
+	switch fin.Symlinks {
+	case "", SymlinksFollow, SymlinksPreserve:
+	default:
+		multiErr = multierror.Append(multiErr, fmt.Errorf("unknown finalize.symlinks %q", fin.Symlinks))
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// FinalizeLocation returns the location all the finalize instructions copy to.
+//
+// Empty string is returned if there are no finalize instructions or they copy to different locations.
+func FinalizeLocation(finalize []Finalize) string {
+	var location string
+
+	for _, fin := range finalize {
+		to := path.Clean("/" + fin.To)
+
+		if location != "" && location != to {
+			return ""
+		}
+
+		location = to
+	}
+
+	return location
+}
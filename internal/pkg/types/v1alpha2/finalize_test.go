@@ -0,0 +1,64 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestFinalizeValidate(t *testing.T) {
+	fin := v1alpha2.Finalize{
+		From:     "/rootfs",
+		To:       "/",
+		Include:  []string{"usr/lib/*.so*"},
+		Exclude:  []string{"usr/share/doc"},
+		Mode:     "0755",
+		Owner:    "0:0",
+		Symlinks: v1alpha2.SymlinksPreserve,
+	}
+
+	require.NoError(t, fin.Validate())
+	assert.False(t, fin.FollowSymlinks())
+
+	mode, err := fin.FileMode()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), *mode)
+
+	assert.True(t, (&v1alpha2.Finalize{}).FollowSymlinks())
+
+	for _, fin := range []v1alpha2.Finalize{
+		{Exclude: []string{"[a-"}},
+		{Mode: "0999"},
+		{Mode: "4755"},
+		{Owner: "root"},
+		{Symlinks: "copy"},
+	} {
+		assert.Error(t, fin.Validate(), "%+v", fin)
+	}
+}
+
+func TestFinalizeLocation(t *testing.T) {
+	assert.Equal(t, "", v1alpha2.FinalizeLocation(nil))
+
+	assert.Equal(t, "/toolchain", v1alpha2.FinalizeLocation([]v1alpha2.Finalize{
+		{From: "/rootfs/bin", To: "/toolchain/"},
+		{From: "/rootfs/lib", To: "/toolchain"},
+	}))
+
+	assert.Equal(t, "/", v1alpha2.FinalizeLocation([]v1alpha2.Finalize{
+		{From: "/rootfs"},
+	}))
+
+	assert.Equal(t, "", v1alpha2.FinalizeLocation([]v1alpha2.Finalize{
+		{From: "/rootfs/bin", To: "/bin"},
+		{From: "/rootfs/lib", To: "/lib"},
+	}))
+}
@@ -0,0 +1,87 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// OCI image labels for package metadata.
+const (
+	LabelVersion     = "org.opencontainers.image.version"
+	LabelDescription = "org.opencontainers.image.description"
+	LabelLicenses    = "org.opencontainers.image.licenses"
+	LabelURL         = "org.opencontainers.image.url"
+	LabelAuthors     = "org.opencontainers.image.authors"
+)
+
+var licenseRegexp = regexp.MustCompile(`^[A-Za-z0-9.+:() -]+$`)
+
+// Metadata describes the package contents.
+type Metadata struct {
+	Version     string   `yaml:"version,omitempty"`
+	Description string   `yaml:"description,omitempty"`
+	License     string   `yaml:"license,omitempty"`
+	Homepage    string   `yaml:"homepage,omitempty"`
+	Maintainers []string `yaml:"maintainers,omitempty"`
+}
+
+// Validate the metadata.
+func (metadata *Metadata) Validate() error {
+	var multiErr *multierror.Error
+
+	if strings.ContainsAny(metadata.Version, " \t\n") {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("version %q can't contain whitespace", metadata.Version))
+	}
+
+	if strings.Contains(metadata.Description, "\n") {
+		multiErr = multierror.Append(multiErr, errors.New("description should be a single line"))
+	}
+
+	if metadata.License != "" && !licenseRegexp.MatchString(metadata.License) {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("license %q should be SPDX license expression", metadata.License))
+	}
+
+	if metadata.Homepage != "" {
+		if u, err := url.Parse(metadata.Homepage); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("error parsing homepage %q: %w", metadata.Homepage, err))
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("homepage %q should be http(s) URL", metadata.Homepage))
+		}
+	}
+
+	for _, maintainer := range metadata.Maintainers {
+		if strings.TrimSpace(maintainer) == "" {
+			multiErr = multierror.Append(multiErr, errors.New("maintainer can't be empty"))
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// ImageLabels returns metadata as OCI image labels, empty fields are skipped.
+func (metadata *Metadata) ImageLabels() map[string]string {
+	labels := map[string]string{}
+
+	for key, value := range map[string]string{
+		LabelVersion:     metadata.Version,
+		LabelDescription: metadata.Description,
+		LabelLicenses:    metadata.License,
+		LabelURL:         metadata.Homepage,
+		LabelAuthors:     strings.Join(metadata.Maintainers, ", "),
+	} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+
+	return labels
+}
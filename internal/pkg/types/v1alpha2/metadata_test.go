@@ -0,0 +1,46 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestMetadata(t *testing.T) {
+	metadata := v1alpha2.Metadata{
+		Version:     "3.0.5",
+		Description: "GNU parser generator",
+		License:     "GPL-3.0-or-later WITH Bison-exception-2.2",
+		Homepage:    "https://www.gnu.org/software/bison/",
+		Maintainers: []string{"Jane Doe <jane@example.com>", "John Doe"},
+	}
+
+	require.NoError(t, metadata.Validate())
+
+	assert.Equal(t, map[string]string{
+		v1alpha2.LabelVersion:     "3.0.5",
+		v1alpha2.LabelDescription: "GNU parser generator",
+		v1alpha2.LabelLicenses:    "GPL-3.0-or-later WITH Bison-exception-2.2",
+		v1alpha2.LabelURL:         "https://www.gnu.org/software/bison/",
+		v1alpha2.LabelAuthors:     "Jane Doe <jane@example.com>, John Doe",
+	}, metadata.ImageLabels())
+
+	assert.Empty(t, (&v1alpha2.Metadata{}).ImageLabels())
+
+	for _, metadata := range []v1alpha2.Metadata{
+		{Version: "3.0 beta"},
+		{Description: "line1\nline2"},
+		{License: "GPL; MIT"},
+		{Homepage: "ftp://ftp.gnu.org/"},
+		{Maintainers: []string{" "}},
+	} {
+		assert.Error(t, metadata.Validate(), "%+v", metadata)
+	}
+}
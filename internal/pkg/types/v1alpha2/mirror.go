@@ -0,0 +1,72 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Mirrors is a list of Mirror rules.
+type Mirrors []Mirror
+
+// Validate mirrors.
+func (mirrors Mirrors) Validate() error {
+	var multiErr *multierror.Error
+
+	for _, mirror := range mirrors {
+		multiErr = multierror.Append(multiErr, mirror.Validate())
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// Candidates returns list of URLs to download source URL from.
+//
+// Mirror URLs of the first matching rule come first in order, original URL is always the last one.
+func (mirrors Mirrors) Candidates(url string) []string {
+	for _, mirror := range mirrors {
+		if !strings.HasPrefix(url, mirror.Prefix) {
+			continue
+		}
+
+		candidates := make([]string, 0, len(mirror.URLs)+1)
+
+		for _, mirrorURL := range mirror.URLs {
+			candidates = append(candidates, mirrorURL+strings.TrimPrefix(url, mirror.Prefix))
+		}
+
+		return append(candidates, url)
+	}
+
+	return []string{url}
+}
+
+// Mirror describes URL prefix rewrite rule for source downloads.
+//
+// Mirrors never change what gets built: checksums of the source are verified
+// for the content downloaded from any mirror.
+type Mirror struct {
+	Prefix string   `yaml:"prefix,omitempty"`
+	URLs   []string `yaml:"urls,omitempty"`
+}
+
+// Validate mirror.
+func (mirror *Mirror) Validate() error {
+	var multiErr *multierror.Error
+
+	if mirror.Prefix == "" {
+		multiErr = multierror.Append(multiErr, errors.New("mirror.prefix can't be empty"))
+	}
+
+	if len(mirror.URLs) == 0 {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("mirror.urls can't be empty for prefix %q", mirror.Prefix))
+	}
+
+	return multiErr.ErrorOrNil()
+}
@@ -0,0 +1,47 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestMirrorsCandidates(t *testing.T) {
+	mirrors := v1alpha2.Mirrors{
+		{
+			Prefix: "https://ftp.gnu.org/gnu/",
+			URLs: []string{
+				"https://mirror.example.com/gnu/",
+				"https://ftpmirror.gnu.org/",
+			},
+		},
+		{
+			Prefix: "https://ftp.gnu.org/",
+			URLs: []string{
+				"https://mirror.example.com/",
+			},
+		},
+	}
+
+	assert.NoError(t, mirrors.Validate())
+
+	assert.Equal(t, []string{
+		"https://mirror.example.com/gnu/bison/bison-3.0.5.tar.xz",
+		"https://ftpmirror.gnu.org/bison/bison-3.0.5.tar.xz",
+		"https://ftp.gnu.org/gnu/bison/bison-3.0.5.tar.xz",
+	}, mirrors.Candidates("https://ftp.gnu.org/gnu/bison/bison-3.0.5.tar.xz"))
+
+	assert.Equal(t, []string{
+		"https://dl.google.com/go/go1.12.5.src.tar.gz",
+	}, mirrors.Candidates("https://dl.google.com/go/go1.12.5.src.tar.gz"))
+
+	assert.Equal(t, []string{
+		"https://dl.google.com/go/go1.12.5.src.tar.gz",
+	}, v1alpha2.Mirrors(nil).Candidates("https://dl.google.com/go/go1.12.5.src.tar.gz"))
+}
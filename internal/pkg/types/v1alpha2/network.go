@@ -0,0 +1,32 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import "fmt"
+
+// Network is a network mode for step instructions.
+type Network string
+
+// Network modes.
+const (
+	// NetworkDefault inherits network mode (defaults to NetworkSandbox).
+	NetworkDefault Network = ""
+	// NetworkNone disables network access.
+	NetworkNone Network = "none"
+	// NetworkSandbox provides network access via isolated network namespace.
+	NetworkSandbox Network = "sandbox"
+	// NetworkHost uses host network namespace.
+	NetworkHost Network = "host"
+)
+
+// Validate network mode.
+func (network Network) Validate() error {
+	switch network {
+	case NetworkDefault, NetworkNone, NetworkSandbox, NetworkHost:
+		return nil
+	default:
+		return fmt.Errorf("unknown network %q", network)
+	}
+}
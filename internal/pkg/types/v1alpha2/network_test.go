@@ -0,0 +1,21 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestNetworkValidate(t *testing.T) {
+	for _, network := range []v1alpha2.Network{v1alpha2.NetworkDefault, v1alpha2.NetworkNone, v1alpha2.NetworkSandbox, v1alpha2.NetworkHost} {
+		assert.NoError(t, network.Validate())
+	}
+
+	assert.EqualError(t, v1alpha2.Network("bridge").Validate(), `unknown network "bridge"`)
+}
@@ -0,0 +1,62 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// OutputSeparator separates package name and output name in stage references, e.g. `gcc/libs`.
+const OutputSeparator = "/"
+
+// Outputs is a set of named package outputs (subpackages).
+type Outputs map[string]Output
+
+// Validate outputs.
+func (outputs Outputs) Validate() error {
+	var multiErr *multierror.Error
+
+	for name, output := range outputs {
+		if name == "" || strings.Contains(name, OutputSeparator) {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("invalid output name %q", name))
+		}
+
+		if err := output.Validate(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("output %q: %w", name, err))
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// Output is an additional output of the package build (subpackage).
+//
+// Output is built from the same build root as the package itself, but it has its own
+// finalize instructions and runtime dependencies.
+type Output struct {
+	Dependencies Dependencies `yaml:"dependencies,omitempty"`
+	Finalize     []Finalize   `yaml:"finalize,omitempty"`
+}
+
+// Validate the output.
+func (output *Output) Validate() error {
+	var multiErr *multierror.Error
+
+	if len(output.Finalize) == 0 {
+		multiErr = multierror.Append(multiErr, errors.New("finalize steps are missing, this is going to lead to empty output"))
+	}
+
+	multiErr = multierror.Append(multiErr, output.Dependencies.Validate())
+
+	for i, fin := range output.Finalize {
+		multiErr = multierror.Append(multiErr, entryError("finalize", i, fin.Validate()))
+	}
+
+	return multiErr.ErrorOrNil()
+}
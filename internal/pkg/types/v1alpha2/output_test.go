@@ -0,0 +1,54 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestDependencyOutput(t *testing.T) {
+	dep := v1alpha2.Dependency{Stage: "gcc/libs"}
+	assert.Equal(t, "gcc", dep.StageName())
+	assert.Equal(t, "libs", dep.Output())
+
+	dep = v1alpha2.Dependency{Stage: "gcc"}
+	assert.Equal(t, "gcc", dep.StageName())
+	assert.Equal(t, "", dep.Output())
+}
+
+func TestPkgOutputs(t *testing.T) {
+	contents := []byte(`name: gcc
+steps:
+  - install:
+      - make install
+finalize:
+  - from: /rootfs
+outputs:
+  libs:
+    dependencies:
+      - stage: musl
+      - stage: qemu
+        when: eq .ARCH "aarch64"
+    finalize:
+      - from: /rootfs/usr/lib
+        to: /usr/lib
+`)
+
+	pkg, err := v1alpha2.NewPkg("gcc", "pkg.yaml", contents, types.Variables{"ARCH": "x86_64"})
+	require.NoError(t, err)
+
+	require.Contains(t, pkg.Outputs, "libs")
+	assert.Len(t, pkg.Outputs["libs"].Dependencies, 1)
+	assert.Len(t, pkg.Outputs["libs"].Finalize, 1)
+
+	assert.Error(t, v1alpha2.Outputs{"libs": {}}.Validate())
+	assert.Error(t, v1alpha2.Outputs{"a/b": {Finalize: []v1alpha2.Finalize{{}}}}.Validate())
+}
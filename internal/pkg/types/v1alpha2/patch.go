@@ -0,0 +1,114 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/talos-systems/bldr/internal/pkg/environment"
+)
+
+const defaultPatchStrip = 1
+
+// Patches is a collection of Patch.
+type Patches []Patch
+
+// Validate patches.
+func (patches Patches) Validate() error {
+	var multiErr *multierror.Error
+
+	for _, patch := range patches {
+		multiErr = multierror.Append(multiErr, patch.Validate())
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// ForPlatform returns patches which should be applied for the platform.
+func (patches Patches) ForPlatform(platform string) Patches {
+	var result Patches
+
+	for _, patch := range patches {
+		if patch.AppliesTo(platform) {
+			result = append(result, patch)
+		}
+	}
+
+	return result
+}
+
+// Patch describes a patch file applied to the step directory
+// after sources are downloaded and before step instructions are executed.
+type Patch struct {
+	File      string   `yaml:"file,omitempty"`
+	Strip     *int     `yaml:"strip,omitempty"`
+	Platforms []string `yaml:"platforms,omitempty"`
+}
+
+// ContextPath returns path of the patch file relative to the build context root.
+func (patch *Patch) ContextPath(baseDir string) string {
+	return contextPath(baseDir, patch.File)
+}
+
+// StripLevel returns number of leading path components to strip, defaults to 1.
+func (patch *Patch) StripLevel() int {
+	if patch.Strip == nil {
+		return defaultPatchStrip
+	}
+
+	return *patch.Strip
+}
+
+// AppliesTo checks whether patch should be applied for the platform.
+func (patch *Patch) AppliesTo(platform string) bool {
+	if len(patch.Platforms) == 0 {
+		return true
+	}
+
+	for _, p := range patch.Platforms {
+		if p == platform {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate patch.
+func (patch *Patch) Validate() error {
+	var multiErr *multierror.Error
+
+	if patch.File == "" {
+		multiErr = multierror.Append(multiErr, errors.New("patch.file can't be empty"))
+	}
+
+	if patch.StripLevel() < 0 {
+		multiErr = multierror.Append(multiErr, errors.New("patch.strip can't be negative"))
+	}
+
+	for _, platform := range patch.Platforms {
+		if _, ok := environment.Platforms[platform]; !ok {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("unknown patch.platforms %q, supported platforms: %q", platform, supportedPlatforms()))
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+func supportedPlatforms() []string {
+	platforms := make([]string, 0, len(environment.Platforms))
+
+	for id := range environment.Platforms {
+		platforms = append(platforms, id)
+	}
+
+	sort.Strings(platforms)
+
+	return platforms
+}
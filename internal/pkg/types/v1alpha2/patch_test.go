@@ -0,0 +1,31 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestPatchValidate(t *testing.T) {
+	patch := v1alpha2.Patch{
+		File:      "patches/musl-fix.patch",
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+	}
+
+	assert.NoError(t, patch.Validate())
+	assert.True(t, patch.AppliesTo("linux/arm64"))
+	assert.False(t, patch.AppliesTo("linux/armv7"))
+
+	patch.Platforms = []string{"linux/amd46"}
+
+	assert.EqualError(t, patch.Validate(), `1 error occurred:
+	* unknown patch.platforms "linux/amd46", supported platforms: ["linux/amd64" "linux/arm64" "linux/armv7"]
+
+`)
+}
@@ -7,9 +7,10 @@ package v1alpha2
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"strings"
 	"text/template"
 
-	"github.com/Masterminds/sprig/v3"
 	"github.com/hashicorp/go-multierror"
 	"gopkg.in/yaml.v2"
 
@@ -21,48 +22,177 @@ import (
 type Pkg struct {
 	Name         string       `yaml:"name,omitempty"`
 	Variant      Variant      `yaml:"variant,omitempty"`
+	Base         *Dependency  `yaml:"base,omitempty"`
 	Shell        Shell        `yaml:"shell,omitempty"`
+	Network      Network      `yaml:"network,omitempty"`
 	Install      Install      `yaml:"install,omitempty"`
 	Dependencies Dependencies `yaml:"dependencies,omitempty"`
 	Steps        Steps        `yaml:"steps,omitempty"`
 	Finalize     []Finalize   `yaml:"finalize,omitempty"`
+	Outputs      Outputs      `yaml:"outputs,omitempty"`
+
+	Metadata `yaml:",inline"`
 
 	BaseDir  string `yaml:"-"`
 	FileName string `yaml:"-"`
 }
 
+// LoadOptions controls how `pkg.yaml` contents are processed.
+type LoadOptions struct {
+	// Strict enables strict YAML decoding: unknown fields are rejected.
+	Strict bool
+	// StrictTemplates makes references to undefined variables in templates an error.
+	StrictTemplates bool
+	// IgnoreConditions keeps all the entries regardless of `when` conditions (conditions are still evaluated).
+	//
+	// It is used to validate the packages for all the platforms at once.
+	IgnoreConditions bool
+
+	// ReadFile reads the file by path relative to the build tree root, used by `readFile` and `sha256file` template functions.
+	ReadFile func(path string) ([]byte, error)
+	// PackageMetadata returns metadata of the package by name, used by `pkg` template function.
+	PackageMetadata func(name string) (*Metadata, error)
+}
+
 // NewPkg loads Pkg structure from file.
 func NewPkg(baseDir, fileName string, contents []byte, vars types.Variables) (*Pkg, error) {
+	return LoadPkg(baseDir, fileName, contents, vars, LoadOptions{})
+}
+
+// LoadPkg loads Pkg structure from file with specified options.
+func LoadPkg(baseDir, fileName string, contents []byte, vars types.Variables, options LoadOptions) (*Pkg, error) {
 	p := &Pkg{
 		BaseDir:  baseDir,
 		FileName: fileName,
 		Shell:    "/bin/sh",
-		Variant:  Alpine,
 	}
 
-	tmpl, err := template.New(constants.PkgYaml).
-		Funcs(sprig.HermeticTxtFuncMap()).
-		Parse(string(contents))
+	// template is named after the file, so that errors point to the original file
+	name := fileName
+	if name == "" {
+		name = constants.PkgYaml
+	}
+
+	var buf bytes.Buffer
+
+	sourceMap := newSourceMap(name, string(contents), &buf)
+
+	tmpl := template.New(name).
+		Funcs(pkgFuncMap(baseDir, options)).
+		Funcs(sourceMap.funcs())
+
+	if options.StrictTemplates {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+
+	tmpl, err := tmpl.Parse(string(contents))
 	if err != nil {
 		return nil, err
 	}
 
-	var buf bytes.Buffer
+	sourceMap.instrument(tmpl)
+
 	if err = tmpl.Execute(&buf, vars); err != nil {
 		return nil, err
 	}
 
-	if err := yaml.NewDecoder(&buf).Decode(p); err != nil {
-		return nil, err
+	decoder := yaml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	decoder.SetStrict(options.Strict)
+
+	if err = decoder.Decode(p); err != nil {
+		return nil, sourceMap.yamlError(err)
 	}
 
-	if err := p.Validate(); err != nil {
-		return nil, err
+	if p.Variant == "" {
+		// explicit base implies build from scratch
+		if p.Base != nil {
+			p.Variant = Scratch
+		} else {
+			p.Variant = Alpine
+		}
+	}
+
+	if err = p.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if err = p.applyConditions(vars, options); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
 	}
 
 	return p, nil
 }
 
+// applyConditions drops steps, dependencies and finalize entries (including outputs) with false `when` condition.
+func (p *Pkg) applyConditions(vars types.Variables, options LoadOptions) error {
+	var multiErr *multierror.Error
+
+	enabled := func(cond Condition) bool {
+		ok, err := cond.Evaluate(vars, options.StrictTemplates)
+		multiErr = multierror.Append(multiErr, err)
+
+		return ok || options.IgnoreConditions
+	}
+
+	steps := make(Steps, 0, len(p.Steps))
+
+	for i, step := range p.Steps {
+		if !enabled(step.When) {
+			continue
+		}
+
+		// keep step directory and name stable regardless of dropped steps before it
+		step.Index = i
+
+		if step.Workdir == "" {
+			step.TmpDir = fmt.Sprintf(constants.StepTmpDirTemplate, i)
+		}
+
+		steps = append(steps, step)
+	}
+
+	filterDeps := func(in Dependencies) Dependencies {
+		out := make(Dependencies, 0, len(in))
+
+		for _, dep := range in {
+			if enabled(dep.When) {
+				out = append(out, dep)
+			}
+		}
+
+		return out
+	}
+
+	filterFinalize := func(in []Finalize) []Finalize {
+		out := make([]Finalize, 0, len(in))
+
+		for _, fin := range in {
+			if enabled(fin.When) {
+				out = append(out, fin)
+			}
+		}
+
+		return out
+	}
+
+	p.Steps, p.Dependencies, p.Finalize = steps, filterDeps(p.Dependencies), filterFinalize(p.Finalize)
+
+	if len(p.Steps) > 0 && len(p.Finalize) == 0 {
+		multiErr = multierror.Append(multiErr, errors.New("finalize steps are missing after applying `when` conditions, this is going to lead to empty build"))
+	}
+
+	for name, output := range p.Outputs {
+		output.Dependencies, output.Finalize = filterDeps(output.Dependencies), filterFinalize(output.Finalize)
+		p.Outputs[name] = output
+
+		if len(output.Finalize) == 0 {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("output %q: finalize steps are missing after applying `when` conditions, this is going to lead to empty output", name))
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
 // Validate the Pkg.
 func (p *Pkg) Validate() error {
 	var multiErr *multierror.Error
@@ -75,7 +205,66 @@ func (p *Pkg) Validate() error {
 		multiErr = multierror.Append(multiErr, errors.New("finalize steps are missing, this is going to lead to empty build"))
 	}
 
-	multiErr = multierror.Append(multiErr, p.Steps.Validate(), p.Dependencies.Validate())
+	if strings.Contains(p.Name, OutputSeparator) {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("package name %q can't contain %q", p.Name, OutputSeparator))
+	}
+
+	multiErr = multierror.Append(multiErr, p.Network.Validate(), p.Install.Validate(), p.Steps.Validate(), p.Dependencies.Validate(), p.Outputs.Validate(), p.Metadata.Validate())
+
+	for i, fin := range p.Finalize {
+		multiErr = multierror.Append(multiErr, entryError("finalize", i, fin.Validate()))
+	}
+
+	if p.Base != nil {
+		if p.Base.Runtime {
+			multiErr = multierror.Append(multiErr, errors.New("base can't be a runtime dependency"))
+		}
+
+		if p.Base.When != "" {
+			multiErr = multierror.Append(multiErr, errors.New("base can't have a condition"))
+		}
+
+		multiErr = multierror.Append(multiErr, p.Base.Validate())
+	}
 
 	return multiErr.ErrorOrNil()
 }
+
+// entryError prefixes validation errors of the list entry with its position, e.g. `steps[2]`.
+func entryError(list string, i int, err error) error {
+	var multiErr *multierror.Error
+
+	if !errors.As(err, &multiErr) {
+		if err == nil {
+			return nil
+		}
+
+		return fmt.Errorf("%s[%d]: %w", list, i, err)
+	}
+
+	var result *multierror.Error
+
+	for _, e := range multiErr.Errors {
+		result = multierror.Append(result, fmt.Errorf("%s[%d]: %w", list, i, e))
+	}
+
+	return result.ErrorOrNil()
+}
+
+// Warnings returns non-fatal issues found in the Pkg built with the variants.
+func (p *Pkg) Warnings(variants Variants) []string {
+	var warnings []string
+
+	spec, ok := variants[p.Variant]
+	if !ok {
+		return nil
+	}
+
+	if spec.IsScratch() && p.Base == nil && len(p.Dependencies) > 0 {
+		first := p.Dependencies[0]
+
+		warnings = append(warnings, fmt.Sprintf("package %q: promoting the first dependency %q to the base is deprecated, set `base` explicitly", p.Name, first.Image+first.Stage))
+	}
+
+	return warnings
+}
@@ -0,0 +1,137 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestPkgBase(t *testing.T) {
+	pkg, err := v1alpha2.NewPkg("final", "pkg.yaml", []byte(`name: final
+base:
+  stage: rootfs
+dependencies:
+  - stage: tools
+`), types.Variables{})
+	require.NoError(t, err)
+
+	assert.Equal(t, v1alpha2.Scratch, pkg.Variant)
+	assert.Equal(t, "rootfs", pkg.Base.Stage)
+	assert.Empty(t, pkg.Warnings(v1alpha2.DefaultVariants()))
+
+	pkg, err = v1alpha2.NewPkg("final", "pkg.yaml", []byte(`name: final
+variant: alpine
+dependencies:
+  - stage: tools
+`), types.Variables{})
+	require.NoError(t, err)
+
+	assert.Nil(t, pkg.Base)
+	assert.Empty(t, pkg.Warnings(v1alpha2.DefaultVariants()))
+
+	pkg, err = v1alpha2.NewPkg("final", "pkg.yaml", []byte(`name: final
+variant: scratch
+dependencies:
+  - stage: rootfs
+  - stage: tools
+`), types.Variables{})
+	require.NoError(t, err)
+
+	require.Len(t, pkg.Warnings(v1alpha2.DefaultVariants()), 1)
+	assert.Contains(t, pkg.Warnings(v1alpha2.DefaultVariants())[0], `"rootfs"`)
+
+	// any variant without image starts from scratch
+	pkg, err = v1alpha2.NewPkg("final", "pkg.yaml", []byte(`name: final
+variant: bare
+dependencies:
+  - stage: rootfs
+`), types.Variables{})
+	require.NoError(t, err)
+
+	assert.Empty(t, pkg.Warnings(v1alpha2.DefaultVariants()))
+	assert.Len(t, pkg.Warnings(v1alpha2.Variants{"bare": {}}), 1)
+
+	_, err = v1alpha2.NewPkg("final", "pkg.yaml", []byte(`name: final
+base:
+  stage: rootfs
+  runtime: true
+  when: eq .ARCH "x86_64"
+`), types.Variables{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "base can't be a runtime dependency")
+	assert.Contains(t, err.Error(), "base can't have a condition")
+}
+
+func TestPkgStrictTemplates(t *testing.T) {
+	contents := []byte(`name: test
+steps:
+  - build:
+      - echo {{ .TOOLCHIAN }}
+finalize:
+  - from: /
+`)
+	vars := types.Variables{"TOOLCHAIN": "/toolchain"}
+
+	pkg, err := v1alpha2.LoadPkg("test", "test/pkg.yaml", contents, vars, v1alpha2.LoadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha2.Instruction("echo <no value>"), pkg.Steps[0].Build[0])
+
+	_, err = v1alpha2.LoadPkg("test", "test/pkg.yaml", contents, vars, v1alpha2.LoadOptions{StrictTemplates: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `test/pkg.yaml:4:16:`)
+	assert.Contains(t, err.Error(), `"TOOLCHIAN"`)
+}
+
+func TestPkgErrorPositions(t *testing.T) {
+	vars := types.Variables{
+		"MODULES": []interface{}{"zlib", "openssl"},
+	}
+
+	for _, tc := range []struct {
+		contents string
+		expected string
+	}{
+		{
+			contents: "name: test\n{{ if }}\n",
+			expected: "test/pkg.yaml:2:",
+		},
+		{
+			contents: "name: test\nsteps:\n  - build:\n      - {{ .MODULES.zlib }}\n",
+			expected: "test/pkg.yaml:4:",
+		},
+		{
+			contents: "# modules\n{{- range .MODULES }}\n# {{ . }}\n{{- end }}\nname: test\nunknown: field\n",
+			expected: "test/pkg.yaml:6:1: field unknown not found",
+		},
+		{
+			// two lines are rendered from the first line
+			contents: "{{ range .MODULES }}# {{ . }}\n{{ end }}name: test\nsteps:\n  - build:\n    - make\n   prepare:\n",
+			expected: "test/pkg.yaml:5:5: did not find expected '-' indicator",
+		},
+		{
+			// lines rendered from the defined template point to the definition
+			contents: "{{ define \"steps\" }}steps:\n  - build:\n      - make\n    unknown: field\n{{ end }}name: test\n{{ template \"steps\" }}",
+			expected: "test/pkg.yaml:4:5: field unknown not found",
+		},
+		{
+			contents: "name: test\nsteps:\n  - name: build\n  - workdir: src\nfinalize:\n  - from: /\n    mode: 01755\n",
+			expected: "test/pkg.yaml: 2 errors occurred:\n\t* steps[1]: step.workdir \"src\" should be absolute path\n\t* finalize[0]: finalize.mode",
+		},
+		{
+			contents: "name: test\ndependencies:\n  - stage: base\n  - image: alpine\n    stage: base\n",
+			expected: "test/pkg.yaml: 1 error occurred:\n\t* dependencies[1]: dependency can't have both image & stage set",
+		},
+	} {
+		_, err := v1alpha2.LoadPkg("test", "test/pkg.yaml", []byte(tc.contents), vars, v1alpha2.LoadOptions{Strict: true})
+		require.Error(t, err, tc.contents)
+		assert.Contains(t, err.Error(), tc.expected)
+	}
+}
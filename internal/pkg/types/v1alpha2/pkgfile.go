@@ -5,21 +5,55 @@
 package v1alpha2
 
 import (
+	"bytes"
 	"fmt"
+	"regexp"
+	"text/template"
 
+	"github.com/hashicorp/go-multierror"
 	"gopkg.in/yaml.v2"
 
 	"github.com/talos-systems/bldr/internal/pkg/types"
 )
 
+var templateActionRegexp = regexp.MustCompile(`{{.*?}}`)
+
 // Pkgfile describes structure of 'Pkgfile'.
 type Pkgfile struct {
 	Format string            `yaml:"format"`
 	Vars   types.Variables   `yaml:"vars,omitempty"`
 	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// Include lists files with variables and labels merged in order into the Pkgfile,
+	// paths are relative to the Pkgfile and might use variables, e.g. `vars/{{ .ARCH }}.yaml`.
+	Include []string `yaml:"include,omitempty"`
+
+	// Hermetic forces NetworkNone for all the step instructions.
+	Hermetic bool `yaml:"hermetic,omitempty"`
+
+	// StrictTemplates makes references to undefined variables in `pkg.yaml` an error, default depends on the format.
+	StrictTemplates *bool `yaml:"strict-templates,omitempty"`
+
+	Mirrors Mirrors `yaml:"mirrors,omitempty"`
+
+	// Variants declares base images in addition to (or overriding) DefaultVariants.
+	Variants Variants `yaml:"variants,omitempty"`
+}
+
+// AllVariants returns built-in variants merged with variants declared in the Pkgfile.
+//
+// Pkgfile might be nil.
+func (pkgfile *Pkgfile) AllVariants() Variants {
+	if pkgfile == nil {
+		return DefaultVariants()
+	}
+
+	return DefaultVariants().Merge(pkgfile.Variants)
 }
 
 // NewPkgfile loads Pkgfile from `[]byte` contents.
+//
+// Format is not checked here, see package format for the list of supported formats.
 func NewPkgfile(contents []byte) (*Pkgfile, error) {
 	var pkgfile Pkgfile
 
@@ -27,11 +61,90 @@ func NewPkgfile(contents []byte) (*Pkgfile, error) {
 		return nil, err
 	}
 
-	// TODO: this might be used in the future to pick correct format
-	//       based on Pkgfile, leave it simple for now
-	if pkgfile.Format != "v1alpha2" {
-		return nil, fmt.Errorf("unsupported format: %q, supported formats: %q", pkgfile.Format, []string{"v1alpha2"})
+	if err := multierror.Append(pkgfile.Mirrors.Validate(), pkgfile.Variants.Validate()).ErrorOrNil(); err != nil {
+		return nil, err
 	}
 
 	return &pkgfile, nil
 }
+
+// PkgfileInclude describes structure of the file included into the Pkgfile.
+type PkgfileInclude struct {
+	Vars   types.Variables   `yaml:"vars,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// IncludePatterns returns glob patterns matching include files for any values of the variables.
+func (pkgfile *Pkgfile) IncludePatterns() []string {
+	patterns := make([]string, 0, len(pkgfile.Include))
+
+	for _, include := range pkgfile.Include {
+		patterns = append(patterns, templateActionRegexp.ReplaceAllString(include, "*"))
+	}
+
+	return patterns
+}
+
+// LoadIncludes loads include files in order and merges them into the Pkgfile.
+//
+// Include paths are rendered with the variables merged with the Pkgfile variables loaded so far.
+func (pkgfile *Pkgfile) LoadIncludes(vars types.Variables, readFile func(path string) ([]byte, error)) error {
+	if pkgfile.Vars == nil {
+		pkgfile.Vars = types.Variables{}
+	}
+
+	if pkgfile.Labels == nil {
+		pkgfile.Labels = map[string]string{}
+	}
+
+	context := types.Variables{}.Merge(vars).Merge(pkgfile.Vars)
+
+	for _, include := range pkgfile.Include {
+		includePath, err := renderIncludePath(include, context)
+		if err != nil {
+			return err
+		}
+
+		contents, err := readFile(includePath)
+		if err != nil {
+			return fmt.Errorf("error reading include %q: %w", includePath, err)
+		}
+
+		var inc PkgfileInclude
+
+		if err = yaml.UnmarshalStrict(contents, &inc); err != nil {
+			return fmt.Errorf("error parsing include %q: %w", includePath, err)
+		}
+
+		pkgfile.Vars.Merge(inc.Vars)
+		context.Merge(inc.Vars)
+
+		for key, value := range inc.Labels {
+			pkgfile.Labels[key] = value
+		}
+	}
+
+	return nil
+}
+
+func renderIncludePath(include string, vars types.Variables) (string, error) {
+	tmpl, err := template.New(include).
+		Funcs(funcMap()).
+		Option("missingkey=error").
+		Parse(include)
+	if err != nil {
+		return "", fmt.Errorf("error parsing include path %q: %w", include, err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("error rendering include path %q: %w", include, err)
+	}
+
+	includePath, ok := relativePath(buf.String())
+	if !ok {
+		return "", fmt.Errorf("include path %q should be relative to the Pkgfile", buf.String())
+	}
+
+	return includePath, nil
+}
@@ -0,0 +1,129 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestPkgfileIncludes(t *testing.T) {
+	pkgfile, err := v1alpha2.NewPkgfile([]byte(`format: v1alpha2
+vars:
+  GCC_VERSION: 10.2.0
+  TEAM: toolchain
+labels:
+  org.opencontainers.image.source: https://github.com/talos-systems/pkgs
+include:
+  - vars/versions.yaml
+  - vars/{{ .TEAM }}.yaml
+  - vars/{{ .ARCH }}.yaml
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"vars/versions.yaml", "vars/*.yaml", "vars/*.yaml"}, pkgfile.IncludePatterns())
+
+	files := map[string]string{
+		"vars/versions.yaml": `vars:
+  GCC_VERSION: 10.3.0
+  MUSL_VERSION: 1.2.2
+  TEAM: kernel
+`,
+		"vars/kernel.yaml": `vars:
+  KERNEL_VERSION: 5.10.43
+labels:
+  org.opencontainers.image.vendor: kernel
+`,
+		"vars/aarch64.yaml": `vars:
+  MUSL_VERSION: 1.2.1
+`,
+	}
+
+	readFile := func(path string) ([]byte, error) {
+		contents, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+
+		return []byte(contents), nil
+	}
+
+	require.NoError(t, pkgfile.LoadIncludes(types.Variables{"ARCH": "aarch64"}, readFile))
+
+	assert.Equal(t, types.Variables{
+		"GCC_VERSION":    "10.3.0",
+		"MUSL_VERSION":   "1.2.1",
+		"TEAM":           "kernel",
+		"KERNEL_VERSION": "5.10.43",
+	}, pkgfile.Vars)
+
+	assert.Equal(t, map[string]string{
+		"org.opencontainers.image.source": "https://github.com/talos-systems/pkgs",
+		"org.opencontainers.image.vendor": "kernel",
+	}, pkgfile.Labels)
+
+	for _, include := range []string{
+		"vars/{{ .UNDEFINED }}.yaml",
+		"../vars.yaml",
+		"/vars.yaml",
+		"vars/missing.yaml",
+	} {
+		pkgfile = &v1alpha2.Pkgfile{Include: []string{include}}
+
+		assert.Error(t, pkgfile.LoadIncludes(types.Variables{}, readFile), include)
+	}
+
+	pkgfile = &v1alpha2.Pkgfile{Include: []string{"vars/aarch64.yaml"}}
+	files["vars/aarch64.yaml"] = "variables:\n  FOO: bar\n"
+
+	assert.Error(t, pkgfile.LoadIncludes(types.Variables{}, readFile))
+}
+
+func TestPkgfileTypedVars(t *testing.T) {
+	pkgfile, err := v1alpha2.NewPkgfile([]byte(`format: v1alpha2
+vars:
+  GO_VERSION: 1.20
+  MODULES:
+    - zlib
+    - openssl
+  TRIPLETS:
+    x86_64: x86_64-linux-musl
+    aarch64: aarch64-linux-musl
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, types.Variables{
+		"GO_VERSION": "1.20",
+		"MODULES":    []interface{}{"zlib", "openssl"},
+		"TRIPLETS": map[string]interface{}{
+			"x86_64":  "x86_64-linux-musl",
+			"aarch64": "aarch64-linux-musl",
+		},
+	}, pkgfile.Vars)
+
+	vars := types.Variables{"ARCH": "aarch64"}.Merge(pkgfile.Vars)
+
+	pkg, err := v1alpha2.NewPkg("modules", "pkg.yaml", []byte(`name: modules
+steps:
+{{- range .MODULES }}
+  - build:
+      - make {{ . }} TARGET={{ index $.TRIPLETS $.ARCH }} GO={{ $.GO_VERSION }}
+{{- end }}
+finalize:
+  - from: /rootfs
+    to: /
+`), vars)
+	require.NoError(t, err)
+
+	require.Len(t, pkg.Steps, 2)
+	assert.Equal(t, v1alpha2.Instruction("make zlib TARGET=aarch64-linux-musl GO=1.20"), pkg.Steps[0].Build[0])
+	assert.Equal(t, v1alpha2.Instruction("make openssl TARGET=aarch64-linux-musl GO=1.20"), pkg.Steps[1].Build[0])
+}
@@ -0,0 +1,197 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const positionMarkerFunc = "__bldr_position"
+
+var yamlLineRegexp = regexp.MustCompile(`line (\d+):`)
+
+// sourceMap maps offsets in the rendered template output back to the template source.
+//
+// Parse tree of the template is instrumented with markers (actions with empty output)
+// before each node, so that every time a node is executed, current output offset
+// is recorded along with the position of the node in the source.
+type sourceMap struct {
+	fileName string
+	source   string
+	output   *bytes.Buffer
+
+	nodes   []parse.Node
+	entries []sourceMapEntry
+}
+
+type sourceMapEntry struct {
+	outputOffset int
+	node         parse.Node
+}
+
+func newSourceMap(fileName, source string, output *bytes.Buffer) *sourceMap {
+	return &sourceMap{
+		fileName: fileName,
+		source:   source,
+		output:   output,
+	}
+}
+
+// funcs returns template functions used by the markers.
+func (sm *sourceMap) funcs() template.FuncMap {
+	return template.FuncMap{
+		positionMarkerFunc: func(idx int) string {
+			sm.entries = append(sm.entries, sourceMapEntry{
+				outputOffset: sm.output.Len(),
+				node:         sm.nodes[idx],
+			})
+
+			return ""
+		},
+	}
+}
+
+// instrument the parsed template (including templates defined in it) with the markers.
+func (sm *sourceMap) instrument(tmpl *template.Template) {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			sm.instrumentList(t.Tree.Root)
+		}
+	}
+}
+
+func (sm *sourceMap) instrumentList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+
+	nodes := make([]parse.Node, 0, 2*len(list.Nodes))
+
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.IfNode:
+			sm.instrumentList(n.List)
+			sm.instrumentList(n.ElseList)
+		case *parse.RangeNode:
+			sm.instrumentList(n.List)
+			sm.instrumentList(n.ElseList)
+		case *parse.WithNode:
+			sm.instrumentList(n.List)
+			sm.instrumentList(n.ElseList)
+		}
+
+		nodes = append(nodes, sm.marker(node), node)
+	}
+
+	list.Nodes = nodes
+}
+
+func (sm *sourceMap) marker(node parse.Node) parse.Node {
+	idx := len(sm.nodes)
+	sm.nodes = append(sm.nodes, node)
+
+	return &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      node.Position(),
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Pos:      node.Position(),
+			Cmds: []*parse.CommandNode{
+				{
+					NodeType: parse.NodeCommand,
+					Pos:      node.Position(),
+					Args: []parse.Node{
+						parse.NewIdentifier(positionMarkerFunc).SetPos(node.Position()),
+						&parse.NumberNode{
+							NodeType: parse.NodeNumber,
+							Pos:      node.Position(),
+							IsInt:    true,
+							Int64:    int64(idx),
+							Text:     strconv.Itoa(idx),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// sourceOffset returns the template source offset which produced the output offset.
+func (sm *sourceMap) sourceOffset(outputOffset int) int {
+	i := sort.Search(len(sm.entries), func(i int) bool {
+		return sm.entries[i].outputOffset > outputOffset
+	}) - 1
+
+	if i < 0 {
+		return outputOffset
+	}
+
+	entry := sm.entries[i]
+	offset := int(entry.node.Position())
+
+	// text is copied to the output as is
+	if text, ok := entry.node.(*parse.TextNode); ok {
+		delta := outputOffset - entry.outputOffset
+		if delta >= len(text.Text) {
+			delta = len(text.Text) - 1
+		}
+
+		if delta > 0 {
+			offset += delta
+		}
+	}
+
+	return offset
+}
+
+// position returns source line and column (1-based) for the output line (1-based).
+func (sm *sourceMap) position(outputLine int) (line, column int) {
+	output := sm.output.String()
+
+	// point to the first non-blank character of the line in the output
+	outputOffset := 0
+
+	for i := 1; i < outputLine; i++ {
+		idx := strings.IndexByte(output[outputOffset:], '\n')
+		if idx == -1 {
+			break
+		}
+
+		outputOffset += idx + 1
+	}
+
+	for outputOffset < len(output) && (output[outputOffset] == ' ' || output[outputOffset] == '\t') {
+		outputOffset++
+	}
+
+	offset := sm.sourceOffset(outputOffset)
+	if offset > len(sm.source) {
+		offset = len(sm.source)
+	}
+
+	line = 1 + strings.Count(sm.source[:offset], "\n")
+	column = 1 + offset - (strings.LastIndexByte(sm.source[:offset], '\n') + 1)
+
+	return line, column
+}
+
+// yamlError rewrites line numbers of the rendered template in YAML errors into source positions.
+func (sm *sourceMap) yamlError(err error) error {
+	return errors.New(yamlLineRegexp.ReplaceAllStringFunc(err.Error(), func(match string) string {
+		outputLine, _ := strconv.Atoi(yamlLineRegexp.FindStringSubmatch(match)[1]) //nolint:errcheck
+
+		line, column := sm.position(outputLine)
+
+		return fmt.Sprintf("%s:%d:%d:", sm.fileName, line, column)
+	}))
+}
@@ -0,0 +1,93 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alessio/shellescape"
+	"github.com/hashicorp/go-multierror"
+)
+
+const secretsDir = "/run/secrets"
+
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Secrets is a collection of Secret.
+type Secrets []Secret
+
+// Validate secrets.
+func (secrets Secrets) Validate() error {
+	var multiErr *multierror.Error
+
+	for _, secret := range secrets {
+		multiErr = multierror.Append(multiErr, secret.Validate())
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// Exports returns shell script which exports secrets with `env` set as environment variables.
+//
+// Assignment is separate from `export`, so that failure to read the secret fails the script.
+func (secrets Secrets) Exports() string {
+	var sb strings.Builder
+
+	for _, secret := range secrets {
+		if secret.Env == "" {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "%s=\"$(cat %s)\"; export %s\n", secret.Env, shellescape.Quote(secret.Path()), secret.Env)
+	}
+
+	return sb.String()
+}
+
+// Secret describes build secret provided by the client.
+//
+// Secret is mounted as a file into the step instructions, so that its value
+// never appears in the LLB or image history.
+type Secret struct {
+	ID     string `yaml:"id,omitempty"`
+	Target string `yaml:"target,omitempty"`
+	Env    string `yaml:"env,omitempty"`
+}
+
+// Path returns path secret is mounted at.
+func (secret *Secret) Path() string {
+	if secret.Target != "" {
+		return secret.Target
+	}
+
+	return filepath.Join(secretsDir, secret.ID)
+}
+
+// Validate secret.
+func (secret *Secret) Validate() error {
+	var multiErr *multierror.Error
+
+	if secret.ID == "" {
+		multiErr = multierror.Append(multiErr, errors.New("secret.id can't be empty"))
+	}
+
+	if secret.Target != "" && secret.Env != "" {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("secret can't have both target & env set: %q, %q", secret.Target, secret.Env))
+	}
+
+	if secret.Target != "" && !filepath.IsAbs(secret.Target) {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("secret.target %q should be absolute path", secret.Target))
+	}
+
+	if secret.Env != "" && !envNameRegexp.MatchString(secret.Env) {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("secret.env %q is not a valid environment variable name", secret.Env))
+	}
+
+	return multiErr.ErrorOrNil()
+}
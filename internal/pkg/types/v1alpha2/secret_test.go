@@ -0,0 +1,35 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestSecretsExports(t *testing.T) {
+	secrets := v1alpha2.Secrets{
+		{ID: "netrc", Target: "/root/.netrc"},
+		{ID: "token", Env: "TOKEN"},
+	}
+
+	assert.NoError(t, secrets.Validate())
+	assert.Equal(t, "TOKEN=\"$(cat /run/secrets/token)\"; export TOKEN\n", secrets.Exports())
+}
+
+func TestSecretValidate(t *testing.T) {
+	secret := v1alpha2.Secret{Target: "root/.netrc", Env: "1TOKEN"}
+
+	assert.EqualError(t, secret.Validate(), `4 errors occurred:
+	* secret.id can't be empty
+	* secret can't have both target & env set: "root/.netrc", "1TOKEN"
+	* secret.target "root/.netrc" should be absolute path
+	* secret.env "1TOKEN" is not a valid environment variable name
+
+`)
+}
@@ -14,6 +14,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 )
@@ -33,11 +37,77 @@ func (sources Sources) Validate() error {
 }
 
 // Source describe build source to be downloaded.
+//
+// Source is either downloaded over HTTP(S) (`url`), copied from the build context (`file`),
+// or checked out from git repository (`git`).
 type Source struct {
 	URL         string `yaml:"url,omitempty"`
+	File        string `yaml:"file,omitempty"`
 	Destination string `yaml:"destination,omitempty"`
 	SHA256      string `yaml:"sha256,omitempty"`
 	SHA512      string `yaml:"sha512,omitempty"`
+
+	Extract         bool   `yaml:"extract,omitempty"`
+	StripComponents int    `yaml:"strip-components,omitempty"`
+	Subdir          string `yaml:"subdir,omitempty"`
+
+	Git        string `yaml:"git,omitempty"`
+	Commit     string `yaml:"commit,omitempty"`
+	Submodules *bool  `yaml:"submodules,omitempty"`
+	KeepGitDir bool   `yaml:"keep-git-dir,omitempty"`
+}
+
+// ArchiveExtensions is a list of archive file extensions supported for extraction.
+var ArchiveExtensions = []string{
+	".tar",
+	".tar.gz", ".tgz",
+	".tar.xz", ".txz",
+	".tar.bz2", ".tbz2",
+	".tar.zst",
+	".zip",
+}
+
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// CheckoutSubmodules returns whether git submodules should be checked out, defaults to true.
+func (source *Source) CheckoutSubmodules() bool {
+	if source.Submodules == nil {
+		return true
+	}
+
+	return *source.Submodules
+}
+
+// IsGit checks whether source is a git repository.
+func (source *Source) IsGit() bool {
+	return source.Git != ""
+}
+
+// IsFile checks whether source is a file from the build context.
+func (source *Source) IsFile() bool {
+	return source.File != ""
+}
+
+// ContextPath returns path of the file source relative to the build context root.
+func (source *Source) ContextPath(baseDir string) string {
+	return contextPath(baseDir, source.File)
+}
+
+// contextPath returns path of the file relative to the build context root.
+//
+// Absolute paths are relative to the build context root, other paths are relative
+// to the package directory baseDir.
+func contextPath(baseDir, p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+
+	return filepath.Join("/", baseDir, p)
+}
+
+// ToSHA256Sum returns in format of line expected by 'sha256sum'.
+func (source *Source) ToSHA256Sum() []byte {
+	return []byte(source.SHA256 + " *" + source.Destination + "\n")
 }
 
 // ToSHA512Sum returns in format of line expected by 'sha512sum'.
@@ -47,6 +117,17 @@ func (source *Source) ToSHA512Sum() []byte {
 
 // Validate source.
 func (source *Source) Validate() error {
+	switch {
+	case source.IsGit():
+		return source.validateGit()
+	case source.IsFile():
+		return source.validateFile()
+	default:
+		return source.validateHTTP()
+	}
+}
+
+func (source *Source) validateHTTP() error {
 	var multiErr *multierror.Error
 
 	if source.URL == "" {
@@ -55,6 +136,20 @@ func (source *Source) Validate() error {
 		multiErr = multierror.Append(multiErr, fmt.Errorf("error parsing source.url %q: %w", source.URL, err))
 	}
 
+	return source.validateChecksummed(multiErr).ErrorOrNil()
+}
+
+func (source *Source) validateFile() error {
+	var multiErr *multierror.Error
+
+	if source.URL != "" {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("source can't have both url & file set: %q, %q", source.URL, source.File))
+	}
+
+	return source.validateChecksummed(multiErr).ErrorOrNil()
+}
+
+func (source *Source) validateChecksummed(multiErr *multierror.Error) *multierror.Error {
 	if source.Destination == "" {
 		multiErr = multierror.Append(multiErr, errors.New("source.destination can't be empty"))
 	}
@@ -77,13 +172,106 @@ func (source *Source) Validate() error {
 		multiErr = multierror.Append(multiErr, errors.New("source.sha512 should be 128 chars long"))
 	}
 
+	if source.Commit != "" || source.Submodules != nil || source.KeepGitDir {
+		multiErr = multierror.Append(multiErr, errors.New("source.commit, source.submodules and source.keep-git-dir can be used only with source.git"))
+	}
+
+	return source.validateExtract(multiErr)
+}
+
+func (source *Source) validateExtract(multiErr *multierror.Error) *multierror.Error {
+	if !source.Extract {
+		if source.StripComponents != 0 || source.Subdir != "" {
+			multiErr = multierror.Append(multiErr, errors.New("source.strip-components and source.subdir can be used only with source.extract"))
+		}
+
+		return multiErr
+	}
+
+	if !source.isArchive() {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("source.destination %q is not a supported archive, supported extensions: %q", source.Destination, ArchiveExtensions))
+	}
+
+	if source.StripComponents < 0 {
+		multiErr = multierror.Append(multiErr, errors.New("source.strip-components can't be negative"))
+	}
+
+	if source.Subdir != "" && (filepath.IsAbs(source.Subdir) || strings.HasPrefix(filepath.Clean(source.Subdir), "..")) {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("source.subdir %q should be relative to the step directory", source.Subdir))
+	}
+
+	return multiErr
+}
+
+func (source *Source) isArchive() bool {
+	for _, ext := range ArchiveExtensions {
+		if strings.HasSuffix(source.Destination, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (source *Source) validateGit() error {
+	var multiErr *multierror.Error
+
+	if source.URL != "" || source.File != "" {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("source can't have both url or file & git set: %q", source.Git))
+	}
+
+	if source.Destination == "" {
+		multiErr = multierror.Append(multiErr, errors.New("source.destination can't be empty"))
+	}
+
+	if source.Commit == "" {
+		multiErr = multierror.Append(multiErr, errors.New("source.commit can't be empty"))
+	} else if !commitRegexp.MatchString(source.Commit) {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("source.commit %q should be full commit hash", source.Commit))
+	}
+
+	if source.SHA256 != "" || source.SHA512 != "" {
+		multiErr = multierror.Append(multiErr, errors.New("source.sha256 and source.sha512 can't be used with source.git, use source.commit"))
+	}
+
+	if source.Extract {
+		multiErr = multierror.Append(multiErr, errors.New("source.extract can't be used with source.git"))
+	}
+
 	return multiErr.ErrorOrNil()
 }
 
 // ValidateChecksums downloads the source, validates checksums,
 // and returns actual checksums and validation error, if any.
-func (source *Source) ValidateChecksums(ctx context.Context) (string, string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", source.URL, nil)
+//
+// Source is downloaded from mirrors first (if any), and then from the original URL.
+func (source *Source) ValidateChecksums(ctx context.Context, mirrors Mirrors) (string, string, error) {
+	var (
+		actualSHA256, actualSHA512 string
+		err                        error
+		multiErr                   *multierror.Error
+	)
+
+	candidates := mirrors.Candidates(source.URL)
+
+	for _, candidate := range candidates {
+		actualSHA256, actualSHA512, err = source.validateURLChecksums(ctx, candidate)
+		if err == nil {
+			return actualSHA256, actualSHA512, nil
+		}
+
+		if len(candidates) == 1 {
+			return actualSHA256, actualSHA512, err
+		}
+
+		multiErr = multierror.Append(multiErr, fmt.Errorf("%s: %w", candidate, err))
+	}
+
+	return actualSHA256, actualSHA512, multiErr.ErrorOrNil()
+}
+
+func (source *Source) validateURLChecksums(ctx context.Context, sourceURL string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sourceURL, nil)
 	if err != nil {
 		return "", "", err
 	}
@@ -95,10 +283,27 @@ func (source *Source) ValidateChecksums(ctx context.Context) (string, string, er
 
 	defer resp.Body.Close() //nolint:errcheck
 
+	return source.validateChecksums(resp.Body)
+}
+
+// ValidateFileChecksums reads the file source from path, validates checksums,
+// and returns actual checksums and validation error, if any.
+func (source *Source) ValidateFileChecksums(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	return source.validateChecksums(f)
+}
+
+func (source *Source) validateChecksums(r io.Reader) (string, string, error) {
 	s256 := sha256.New()
 	s512 := sha512.New()
 
-	if _, err = io.Copy(io.MultiWriter(s256, s512), resp.Body); err != nil {
+	if _, err := io.Copy(io.MultiWriter(s256, s512), r); err != nil {
 		return "", "", err
 	}
 
@@ -108,12 +313,12 @@ func (source *Source) ValidateChecksums(ctx context.Context) (string, string, er
 	)
 
 	if actualSHA256 = hex.EncodeToString(s256.Sum(nil)); source.SHA256 != actualSHA256 {
-		err = fmt.Errorf("%s sha256 does not match: expected %s, got %s", source.Destination, source.SHA256, actualSHA256)
+		err := fmt.Errorf("%s sha256 does not match: expected %s, got %s", source.Destination, source.SHA256, actualSHA256)
 		multiErr = multierror.Append(multiErr, err)
 	}
 
 	if actualSHA512 = hex.EncodeToString(s512.Sum(nil)); source.SHA512 != actualSHA512 {
-		err = fmt.Errorf("%s sha512 does not match: expected %s, got %s", source.Destination, source.SHA512, actualSHA512)
+		err := fmt.Errorf("%s sha512 does not match: expected %s, got %s", source.Destination, source.SHA512, actualSHA512)
 		multiErr = multierror.Append(multiErr, err)
 	}
 
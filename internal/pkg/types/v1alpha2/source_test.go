@@ -6,6 +6,9 @@ package v1alpha2_test
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -33,7 +36,7 @@ func TestSourceValidateChecksums(t *testing.T) {
 		SHA512:      expectedSHA512,
 	}
 
-	actualSHA256, actualSHA512, err := source.ValidateChecksums(context.Background())
+	actualSHA256, actualSHA512, err := source.ValidateChecksums(context.Background(), nil)
 	require.NoError(t, err)
 	assert.Equal(t, expectedSHA256, actualSHA256)
 	assert.Equal(t, expectedSHA512, actualSHA512)
@@ -41,7 +44,7 @@ func TestSourceValidateChecksums(t *testing.T) {
 	source.SHA256 = strings.Repeat("0", 64)
 	source.SHA512 = strings.Repeat("1", 64)
 
-	actualSHA256, actualSHA512, err = source.ValidateChecksums(context.Background())
+	actualSHA256, actualSHA512, err = source.ValidateChecksums(context.Background(), nil)
 	assert.EqualError(t, err, `2 errors occurred:
 	* go1.12.5.src.tar.gz sha256 does not match: expected 0000000000000000000000000000000000000000000000000000000000000000, got 2aa5f088cbb332e73fc3def546800616b38d3bfe6b8713b8a6404060f22503e8
 	* go1.12.5.src.tar.gz sha512 does not match: expected 1111111111111111111111111111111111111111111111111111111111111111, got ce64105ff71615f9d235cc7c8656b6409fc40cc90d15a28d355fadd9072d2eab842af379dd8bba0f1181715753143e4a07491e0f9e5f8df806327d7c95a34fae
@@ -50,3 +53,94 @@ func TestSourceValidateChecksums(t *testing.T) {
 	assert.Equal(t, expectedSHA256, actualSHA256)
 	assert.Equal(t, expectedSHA512, actualSHA512)
 }
+
+func TestSourceValidateGit(t *testing.T) {
+	source := v1alpha2.Source{
+		Git:         "https://github.com/talos-systems/bldr.git",
+		Commit:      "0123456789abcdef0123456789abcdef01234567",
+		Destination: "bldr",
+	}
+
+	assert.NoError(t, source.Validate())
+
+	disabled := false
+	source.Submodules = &disabled
+
+	assert.NoError(t, source.Validate())
+	assert.False(t, source.CheckoutSubmodules())
+
+	source.Commit = "v0.2.0"
+	source.SHA256 = strings.Repeat("0", 64)
+
+	assert.EqualError(t, source.Validate(), `2 errors occurred:
+	* source.commit "v0.2.0" should be full commit hash
+	* source.sha256 and source.sha512 can't be used with source.git, use source.commit
+
+`)
+}
+
+//nolint:lll
+func TestSourceValidateFileChecksums(t *testing.T) {
+	source := v1alpha2.Source{
+		File:        "vendor/hello.txt",
+		Destination: "hello.txt",
+		SHA256:      "853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020",
+		SHA512:      "f65f341b35981fda842b09b2c8af9bcdb7602a4c2e6fa1f7d41f0974d3e3122f268fc79d5a4af66358f5133885cd1c165c916f80ab25e5d8d95db46f803c782c",
+	}
+
+	require.NoError(t, source.Validate())
+	assert.Equal(t, "/hello/vendor/hello.txt", source.ContextPath("hello"))
+
+	root := t.TempDir()
+	path := filepath.Join(root, source.ContextPath("hello"))
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte("hello, world\n"), 0644))
+
+	_, _, err := source.ValidateFileChecksums(path)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("hello, world!\n"), 0644))
+
+	_, _, err = source.ValidateFileChecksums(path)
+	assert.Error(t, err)
+}
+
+func TestSourceValidateExtract(t *testing.T) {
+	source := v1alpha2.Source{
+		URL:             "https://ftp.gnu.org/gnu/bison/bison-3.0.5.tar.xz",
+		Destination:     "bison.tar.xz",
+		SHA256:          strings.Repeat("0", 64),
+		SHA512:          strings.Repeat("1", 128),
+		Extract:         true,
+		StripComponents: 1,
+	}
+
+	assert.NoError(t, source.Validate())
+
+	source.Destination = "bison.rar"
+	source.Subdir = "../bison"
+
+	assert.EqualError(t, source.Validate(), `2 errors occurred:
+	* source.destination "bison.rar" is not a supported archive, supported extensions: [".tar" ".tar.gz" ".tgz" ".tar.xz" ".txz" ".tar.bz2" ".tbz2" ".tar.zst" ".zip"]
+	* source.subdir "../bison" should be relative to the step directory
+
+`)
+}
+
+func TestSourceValidateGitOptions(t *testing.T) {
+	enabled := true
+
+	source := v1alpha2.Source{
+		File:        "vendor/hello.txt",
+		Destination: "hello.txt",
+		SHA256:      strings.Repeat("0", 64),
+		SHA512:      strings.Repeat("1", 128),
+		Submodules:  &enabled,
+	}
+
+	assert.EqualError(t, source.Validate(), `1 error occurred:
+	* source.commit, source.submodules and source.keep-git-dir can be used only with source.git
+
+`)
+}
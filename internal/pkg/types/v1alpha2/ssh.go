@@ -0,0 +1,73 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	defaultSSHID           = "default"
+	defaultSSHTargetFormat = "/run/buildkit/ssh_agent.%d"
+)
+
+// SSHMounts is a collection of SSHMount.
+type SSHMounts []SSHMount
+
+// Validate SSH mounts.
+func (mounts SSHMounts) Validate() error {
+	var multiErr *multierror.Error
+
+	for _, mount := range mounts {
+		multiErr = multierror.Append(multiErr, mount.Validate())
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// AuthSock returns value of SSH_AUTH_SOCK for the step, first socket is used.
+func (mounts SSHMounts) AuthSock() string {
+	if len(mounts) == 0 {
+		return ""
+	}
+
+	return mounts[0].Path(0)
+}
+
+// SSHMount describes SSH agent socket forwarded from the client.
+type SSHMount struct {
+	ID     string `yaml:"id,omitempty"`
+	Target string `yaml:"target,omitempty"`
+}
+
+// SSHID returns ID of the SSH agent, defaults to "default".
+func (mount *SSHMount) SSHID() string {
+	if mount.ID != "" {
+		return mount.ID
+	}
+
+	return defaultSSHID
+}
+
+// Path returns socket path, i is an index of the mount in the list.
+func (mount *SSHMount) Path(i int) string {
+	if mount.Target != "" {
+		return mount.Target
+	}
+
+	return fmt.Sprintf(defaultSSHTargetFormat, i)
+}
+
+// Validate SSH mount.
+func (mount *SSHMount) Validate() error {
+	if mount.Target != "" && !filepath.IsAbs(mount.Target) {
+		return fmt.Errorf("ssh.target %q should be absolute path", mount.Target)
+	}
+
+	return nil
+}
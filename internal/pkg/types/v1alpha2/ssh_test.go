@@ -0,0 +1,37 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestSSHMounts(t *testing.T) {
+	mounts := v1alpha2.SSHMounts{
+		{},
+		{ID: "deploy", Target: "/run/deploy.sock"},
+	}
+
+	assert.NoError(t, mounts.Validate())
+
+	assert.Equal(t, "default", mounts[0].SSHID())
+	assert.Equal(t, "/run/buildkit/ssh_agent.0", mounts[0].Path(0))
+
+	assert.Equal(t, "deploy", mounts[1].SSHID())
+	assert.Equal(t, "/run/deploy.sock", mounts[1].Path(1))
+
+	assert.Equal(t, "/run/buildkit/ssh_agent.0", mounts.AuthSock())
+	assert.Empty(t, v1alpha2.SSHMounts{}.AuthSock())
+}
+
+func TestSSHMountValidate(t *testing.T) {
+	mount := v1alpha2.SSHMount{Target: "run/ssh.sock"}
+
+	assert.EqualError(t, mount.Validate(), `ssh.target "run/ssh.sock" should be absolute path`)
+}
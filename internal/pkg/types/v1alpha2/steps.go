@@ -4,7 +4,14 @@
 
 package v1alpha2
 
-import "github.com/hashicorp/go-multierror"
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
 
 // Environment is a set of environment variables to be set in the step.
 type Environment map[string]string
@@ -16,8 +23,8 @@ type Steps []Step
 func (steps Steps) Validate() error {
 	var multiErr *multierror.Error
 
-	for _, step := range steps {
-		multiErr = multierror.Append(multiErr, step.Validate())
+	for i, step := range steps {
+		multiErr = multierror.Append(multiErr, entryError("steps", i, step.Validate()))
 	}
 
 	return multiErr.ErrorOrNil()
@@ -26,19 +33,67 @@ func (steps Steps) Validate() error {
 // Step describes a single build step.
 //
 // Steps are executed sequentially, each step runs in its own
-// empty temporary directory.
+// empty temporary directory (unless workdir is set).
 type Step struct {
+	Name    string       `yaml:"name,omitempty"`
+	When    Condition    `yaml:"when,omitempty"`
+	Workdir string       `yaml:"workdir,omitempty"`
+	User    string       `yaml:"user,omitempty"`
 	Sources Sources      `yaml:"sources,omitempty"`
+	Patches Patches      `yaml:"patches,omitempty"`
 	Env     Environment  `yaml:"env,omitempty"`
+	Cache   CacheMounts  `yaml:"cache,omitempty"`
+	Secrets Secrets      `yaml:"secrets,omitempty"`
+	SSH     SSHMounts    `yaml:"ssh,omitempty"`
+	Network Network      `yaml:"network,omitempty"`
 	Prepare Instructions `yaml:"prepare,omitempty"`
 	Build   Instructions `yaml:"build,omitempty"`
 	Install Instructions `yaml:"install,omitempty"`
 	Test    Instructions `yaml:"test,omitempty"`
 
 	TmpDir string `yaml:"-"`
+	// Index is the index of the step in `pkg.yaml` (before applying `when` conditions).
+	Index int `yaml:"-"`
 }
 
 // Validate the step.
 func (step *Step) Validate() error {
-	return step.Sources.Validate()
+	var multiErr *multierror.Error
+
+	if step.Workdir != "" {
+		if !filepath.IsAbs(step.Workdir) {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("step.workdir %q should be absolute path", step.Workdir))
+		}
+
+		for _, element := range strings.Split(filepath.ToSlash(step.Workdir), "/") {
+			if element == ".." {
+				multiErr = multierror.Append(multiErr, fmt.Errorf("step.workdir %q can't contain %q", step.Workdir, ".."))
+
+				break
+			}
+		}
+	}
+
+	multiErr = multierror.Append(multiErr,
+		step.When.Validate(),
+		step.Sources.Validate(),
+		step.Patches.Validate(),
+		step.Cache.Validate(),
+		step.Secrets.Validate(),
+		step.SSH.Validate(),
+		step.Network.Validate(),
+	)
+
+	return multiErr.ErrorOrNil()
+}
+
+// DisplayName returns step name for the build progress and error messages.
+//
+// If step name is not set, index of the step in `pkg.yaml` is used.
+func (step *Step) DisplayName() string {
+	if step.Name != "" {
+		return step.Name
+	}
+
+	return strconv.Itoa(step.Index)
 }
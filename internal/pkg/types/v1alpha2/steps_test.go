@@ -0,0 +1,53 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestStepValidateWorkdir(t *testing.T) {
+	for _, tc := range []struct {
+		workdir  string
+		expected string
+	}{
+		{
+			workdir: "",
+		},
+		{
+			workdir: "/src/build",
+		},
+		{
+			workdir:  "src/build",
+			expected: `step.workdir "src/build" should be absolute path`,
+		},
+		{
+			workdir:  "/src/../etc",
+			expected: `step.workdir "/src/../etc" can't contain ".."`,
+		},
+	} {
+		step := v1alpha2.Step{Workdir: tc.workdir}
+		err := step.Validate()
+
+		if tc.expected == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expected)
+		}
+	}
+}
+
+func TestStepDisplayName(t *testing.T) {
+	step := v1alpha2.Step{Index: 2}
+	assert.Equal(t, "2", step.DisplayName())
+
+	step.Name = "configure"
+	assert.Equal(t, "configure", step.DisplayName())
+}
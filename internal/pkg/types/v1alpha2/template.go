@@ -0,0 +1,119 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// goArches maps bldr architecture names to GOARCH.
+var goArches = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"armv7":   "arm",
+	"armv6":   "arm",
+	"i386":    "386",
+	"i686":    "386",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// rustTargets maps bldr architecture names to Rust target triples.
+var rustTargets = map[string]string{
+	"x86_64":  "x86_64-unknown-linux-musl",
+	"aarch64": "aarch64-unknown-linux-musl",
+	"armv7":   "armv7-unknown-linux-musleabihf",
+	"armv6":   "arm-unknown-linux-musleabihf",
+	"i386":    "i586-unknown-linux-musl",
+	"i686":    "i686-unknown-linux-musl",
+	"ppc64le": "powerpc64le-unknown-linux-musl",
+	"s390x":   "s390x-unknown-linux-gnu",
+	"riscv64": "riscv64gc-unknown-linux-musl",
+}
+
+func archMapper(kind string, mapping map[string]string) func(arch string) (string, error) {
+	return func(arch string) (string, error) {
+		if mapped, ok := mapping[arch]; ok {
+			return mapped, nil
+		}
+
+		return "", fmt.Errorf("no %s for architecture %q", kind, arch)
+	}
+}
+
+// funcMap returns template functions which don't depend on the build tree.
+func funcMap() template.FuncMap {
+	funcs := sprig.HermeticTxtFuncMap()
+
+	funcs["goarch"] = archMapper("GOARCH", goArches)
+	funcs["rusttarget"] = archMapper("Rust target", rustTargets)
+
+	return funcs
+}
+
+// pkgFuncMap returns template functions for `pkg.yaml` of the package in the baseDir.
+func pkgFuncMap(baseDir string, options LoadOptions) template.FuncMap {
+	funcs := funcMap()
+
+	readFile := func(name string) ([]byte, error) {
+		if options.ReadFile == nil {
+			return nil, fmt.Errorf("can't read %q: reading files is not supported", name)
+		}
+
+		relPath, ok := relativePath(name)
+		if !ok {
+			return nil, fmt.Errorf("can't read %q: path should be relative to the package directory", name)
+		}
+
+		return options.ReadFile(path.Join(baseDir, relPath))
+	}
+
+	funcs["readFile"] = func(name string) (string, error) {
+		contents, err := readFile(name)
+
+		return string(contents), err
+	}
+
+	funcs["sha256file"] = func(name string) (string, error) {
+		contents, err := readFile(name)
+		if err != nil {
+			return "", err
+		}
+
+		sum := sha256.Sum256(contents)
+
+		return hex.EncodeToString(sum[:]), nil
+	}
+
+	funcs["pkg"] = func(name string) (*Metadata, error) {
+		if options.PackageMetadata == nil {
+			return nil, errors.New("package metadata is not available")
+		}
+
+		return options.PackageMetadata(name)
+	}
+
+	return funcs
+}
+
+// relativePath cleans up the path and checks that it doesn't escape the base directory.
+func relativePath(p string) (string, bool) {
+	p = path.Clean(p)
+
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+
+	return p, true
+}
@@ -0,0 +1,90 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestTemplateFunctions(t *testing.T) {
+	files := map[string]string{
+		"go/VERSION":         "1.17.1",
+		"go/patches/a.patch": "--- a\n+++ b\n",
+	}
+
+	options := v1alpha2.LoadOptions{
+		ReadFile: func(path string) ([]byte, error) {
+			contents, ok := files[path]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+
+			return []byte(contents), nil
+		},
+		PackageMetadata: func(name string) (*v1alpha2.Metadata, error) {
+			if name != "toolchain" {
+				return nil, fmt.Errorf("package %q not found", name)
+			}
+
+			return &v1alpha2.Metadata{Version: "0.3.0"}, nil
+		},
+	}
+
+	vars := types.Variables{
+		"ARCH": "aarch64",
+		"Platform": struct {
+			ID string
+		}{
+			ID: "linux/arm64",
+		},
+	}
+
+	pkg, err := v1alpha2.LoadPkg("go", "go/pkg.yaml", []byte(`name: go
+version: "{{ readFile "VERSION" | trim }}"
+description: "Go built with toolchain {{ (pkg "toolchain").Version }}"
+steps:
+  - env:
+      GOARCH: "{{ goarch .ARCH }}"
+      RUST_TARGET: "{{ rusttarget .ARCH }}"
+      PLATFORM: "{{ .Platform.ID }}"
+      PATCH_SHA256: "{{ sha256file "patches/a.patch" }}"
+    when: eq (goarch .ARCH) "arm64"
+finalize:
+  - from: /rootfs
+    to: /
+`), vars, options)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1.17.1", pkg.Version)
+	assert.Equal(t, "Go built with toolchain 0.3.0", pkg.Description)
+
+	require.Len(t, pkg.Steps, 1)
+	assert.Equal(t, "arm64", pkg.Steps[0].Env["GOARCH"])
+	assert.Equal(t, "aarch64-unknown-linux-musl", pkg.Steps[0].Env["RUST_TARGET"])
+	assert.Equal(t, "linux/arm64", pkg.Steps[0].Env["PLATFORM"])
+	assert.Equal(t, "6e53bf2ad8f234c60294a05a013874a211fe3c03653f48df43ac4ec085ab9a24", pkg.Steps[0].Env["PATCH_SHA256"])
+
+	for _, contents := range []string{
+		`name: {{ goarch "sparc" }}`,
+		`name: {{ readFile "../toolchain/VERSION" }}`,
+		`name: {{ readFile "/etc/passwd" }}`,
+		`name: {{ readFile "MISSING" }}`,
+		`name: {{ (pkg "unknown").Version }}`,
+	} {
+		_, err = v1alpha2.LoadPkg("go", "go/pkg.yaml", []byte(contents), vars, options)
+		assert.Error(t, err, contents)
+	}
+
+	_, err = v1alpha2.NewPkg("go", "go/pkg.yaml", []byte(`name: {{ readFile "VERSION" }}`), vars)
+	assert.Error(t, err)
+}
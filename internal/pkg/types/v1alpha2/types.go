@@ -4,11 +4,39 @@
 
 package v1alpha2
 
-// Install is a list of Alpine package names to install.
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// Install is a list of package names to install.
+//
+// Package might be pinned to the version with `name=version`.
 type Install []string
 
-// Finalize is a set of COPY instructions to finalize the build.
-type Finalize struct {
-	From string `yaml:"from,omitempty"`
-	To   string `yaml:"to,omitempty"`
+// Validate install list.
+func (install Install) Validate() error {
+	var multiErr *multierror.Error
+
+	for _, pkg := range install {
+		name, version, pinned := SplitPackagePin(pkg)
+
+		if name == "" || strings.ContainsAny(pkg, " \t\n") || (pinned && (version == "" || strings.Contains(version, "="))) {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("invalid package %q, expected name or name=version", pkg))
+		}
+	}
+
+	return multiErr.ErrorOrNil()
+}
+
+// SplitPackagePin splits install list entry into package name and version.
+func SplitPackagePin(pkg string) (name, version string, pinned bool) {
+	idx := strings.Index(pkg, "=")
+	if idx == -1 {
+		return pkg, "", false
+	}
+
+	return pkg[:idx], pkg[idx+1:], true
 }
@@ -4,48 +4,152 @@
 
 package v1alpha2
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 
-// Variant is a kind of base build image.
-type Variant int
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/talos-systems/bldr/internal/pkg/constants"
+)
+
+// Variant is a name of the base build image.
+//
+// Variants are declared in the Pkgfile, see DefaultVariants for built-in variants.
+type Variant string
 
 const (
 	// Alpine variant uses Alpine as base image for the build.
-	Alpine Variant = iota
+	Alpine Variant = "alpine"
 	// Scratch variant uses scratch image as base image for the build.
-	Scratch
+	Scratch Variant = "scratch"
 )
 
 func (v Variant) String() string {
-	return []string{"alpine", "scratch"}[v]
+	return string(v)
 }
 
-// UnmarshalYAML implements yaml.Unmarshaller interface.
-func (v *Variant) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var aux string
+// PackageManager is a kind of package manager available in the base image.
+type PackageManager string
 
-	err := unmarshal(&aux)
-	if err != nil {
-		return err
+// Package managers.
+const (
+	// PackageManagerNone means that package installation is not available.
+	PackageManagerNone PackageManager = ""
+	// PackageManagerAPK is Alpine Linux package manager.
+	PackageManagerAPK PackageManager = "apk"
+	// PackageManagerAPT is Debian/Ubuntu package manager.
+	PackageManagerAPT PackageManager = "apt"
+	// PackageManagerDNF is Fedora/RHEL package manager.
+	PackageManagerDNF PackageManager = "dnf"
+)
+
+// Validate package manager.
+func (pm PackageManager) Validate() error {
+	switch pm {
+	case PackageManagerNone, PackageManagerAPK, PackageManagerAPT, PackageManagerDNF:
+		return nil
+	default:
+		return fmt.Errorf("unknown package manager %q", pm)
 	}
+}
 
-	var val Variant
+// VariantSpec describes base image of the variant.
+type VariantSpec struct {
+	// Image reference, empty image means scratch.
+	Image          string         `yaml:"image,omitempty"`
+	PackageManager PackageManager `yaml:"package-manager,omitempty"`
+	// Setup instructions are executed with /bin/sh to prepare base image.
+	Setup Instructions `yaml:"setup,omitempty"`
 
-	switch aux {
-	case Alpine.String():
-		val = Alpine
-	case Scratch.String():
-		val = Scratch
-	default:
-		return fmt.Errorf("unknown variant %q", aux)
+	// Repositories are added to the package manager configuration.
+	Repositories []string `yaml:"repositories,omitempty"`
+	// Keys are paths (relative to the build context root) of repository signing keys.
+	Keys []string `yaml:"keys,omitempty"`
+	// Cache enables persistent cache mount for the package manager cache.
+	Cache bool `yaml:"cache,omitempty"`
+}
+
+// IsScratch checks whether variant starts from scratch image.
+func (spec *VariantSpec) IsScratch() bool {
+	return spec.Image == ""
+}
+
+// Validate the variant spec.
+func (spec *VariantSpec) Validate() error {
+	var multiErr *multierror.Error
+
+	if strings.ContainsAny(spec.Image, " \t\n") {
+		multiErr = multierror.Append(multiErr, fmt.Errorf("image %q can't contain whitespace", spec.Image))
 	}
 
-	*v = val
+	if spec.IsScratch() && (spec.PackageManager != PackageManagerNone || len(spec.Setup) > 0) {
+		multiErr = multierror.Append(multiErr, errors.New("package-manager and setup require image to be set"))
+	}
+
+	if spec.PackageManager == PackageManagerNone && (len(spec.Repositories) > 0 || len(spec.Keys) > 0 || spec.Cache) {
+		multiErr = multierror.Append(multiErr, errors.New("repositories, keys and cache require package-manager to be set"))
+	}
 
-	return nil
+	for _, key := range spec.Keys {
+		if key == "" || strings.HasPrefix(filepath.Clean(key), "..") {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("key %q should be a path in the build context", key))
+		}
+	}
+
+	multiErr = multierror.Append(multiErr, spec.PackageManager.Validate())
+
+	return multiErr.ErrorOrNil()
 }
 
-// MarshalYAML implements yaml.Marshaller interface.
-func (v Variant) MarshalYAML() (interface{}, error) {
-	return v.String(), nil
+// Variants is a set of variants by name.
+type Variants map[Variant]VariantSpec
+
+// DefaultVariants returns built-in variants.
+func DefaultVariants() Variants {
+	return Variants{
+		Alpine: {
+			Image:          constants.DefaultBaseImage,
+			PackageManager: PackageManagerAPK,
+			Setup: Instructions{
+				"apk --no-cache --update add bash",
+				"ln -svf /bin/bash /bin/sh",
+			},
+		},
+		Scratch: {},
+	}
+}
+
+// Merge returns new Variants with built-in variants overridden by other.
+func (variants Variants) Merge(other Variants) Variants {
+	result := make(Variants, len(variants)+len(other))
+
+	for name, spec := range variants {
+		result[name] = spec
+	}
+
+	for name, spec := range other {
+		result[name] = spec
+	}
+
+	return result
+}
+
+// Validate variants.
+func (variants Variants) Validate() error {
+	var multiErr *multierror.Error
+
+	for name, spec := range variants {
+		if name == "" {
+			multiErr = multierror.Append(multiErr, errors.New("variant name can't be empty"))
+		}
+
+		if err := spec.Validate(); err != nil {
+			multiErr = multierror.Append(multiErr, fmt.Errorf("variant %q: %w", name, err))
+		}
+	}
+
+	return multiErr.ErrorOrNil()
 }
@@ -0,0 +1,87 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/bldr/internal/pkg/constants"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+func TestPkgfileVariants(t *testing.T) {
+	pkgfile, err := v1alpha2.NewPkgfile([]byte(`format: v1alpha2
+variants:
+  alpine:
+    image: docker.io/alpine:3.15
+    package-manager: apk
+    setup:
+      - apk --no-cache --update add bash
+  busybox:
+    image: docker.io/library/busybox:1.33.1
+  fedora:
+    image: docker.io/library/fedora:34
+    package-manager: dnf
+    repositories:
+      - https://rpm.example.com/fedora/34/
+    keys:
+      - keys/example.gpg
+    cache: true
+`))
+	require.NoError(t, err)
+
+	variants := pkgfile.AllVariants()
+	assert.Len(t, variants, 4)
+	assert.Equal(t, "docker.io/alpine:3.15", variants[v1alpha2.Alpine].Image)
+
+	scratch := variants[v1alpha2.Scratch]
+	assert.True(t, scratch.IsScratch())
+
+	assert.Equal(t, v1alpha2.PackageManagerNone, variants["busybox"].PackageManager)
+
+	var nilPkgfile *v1alpha2.Pkgfile
+
+	assert.Equal(t, constants.DefaultBaseImage, nilPkgfile.AllVariants()[v1alpha2.Alpine].Image)
+
+	_, err = v1alpha2.NewPkgfile([]byte(`format: v1alpha2
+variants:
+  empty:
+    package-manager: apk
+  pacman:
+    image: archlinux
+    package-manager: pacman
+  busybox:
+    image: busybox
+    cache: true
+  debian:
+    image: debian
+    package-manager: apt
+    keys:
+      - ../key.gpg
+`))
+	assert.Error(t, err)
+}
+
+func TestInstallValidate(t *testing.T) {
+	assert.NoError(t, v1alpha2.Install{"bash", "gcc=10.3.1_git20210424-r2", "libstdc++"}.Validate())
+
+	for _, install := range []v1alpha2.Install{
+		{""},
+		{"=1.0"},
+		{"gcc="},
+		{"gcc=1=2"},
+		{"gcc make"},
+	} {
+		assert.Error(t, install.Validate(), "%q", install)
+	}
+
+	name, version, pinned := v1alpha2.SplitPackagePin("gcc=10.3.1")
+	assert.Equal(t, "gcc", name)
+	assert.Equal(t, "10.3.1", version)
+	assert.True(t, pinned)
+}
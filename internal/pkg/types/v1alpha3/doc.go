@@ -0,0 +1,10 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+// Package v1alpha3 contains type definitions for `pkg.yaml`.
+//
+// v1alpha3 shares types with v1alpha2, but it is processed more strictly:
+// unknown fields in `pkg.yaml` are rejected, and features deprecated in v1alpha2
+// are not allowed.
+package v1alpha3
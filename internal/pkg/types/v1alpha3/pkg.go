@@ -0,0 +1,22 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha3
+
+import (
+	"github.com/talos-systems/bldr/internal/pkg/types"
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+// Pkg represents build instructions for a single package.
+type Pkg = v1alpha2.Pkg
+
+// LoadPkg loads Pkg structure from file with specified options.
+//
+// YAML decoding is always strict.
+func LoadPkg(baseDir, fileName string, contents []byte, vars types.Variables, options v1alpha2.LoadOptions) (*Pkg, error) {
+	options.Strict = true
+
+	return v1alpha2.LoadPkg(baseDir, fileName, contents, vars, options)
+}
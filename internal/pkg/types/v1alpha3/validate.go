@@ -0,0 +1,26 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package v1alpha3
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/talos-systems/bldr/internal/pkg/types/v1alpha2"
+)
+
+// Validate checks the rules which are enforced only in v1alpha3.
+//
+// Features deprecated in v1alpha2 (reported as warnings) are errors in v1alpha3.
+func Validate(pkg *Pkg, variants v1alpha2.Variants) error {
+	var multiErr *multierror.Error
+
+	for _, warning := range pkg.Warnings(variants) {
+		multiErr = multierror.Append(multiErr, errors.New(warning))
+	}
+
+	return multiErr.ErrorOrNil()
+}
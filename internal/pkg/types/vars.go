@@ -6,7 +6,10 @@
 package types
 
 // Variables presents generic variables for templating/environment.
-type Variables map[string]string
+//
+// Values are either strings (scalars), lists ([]interface{}) or maps (map[string]interface{}),
+// built-in variables might also contain structures (e.g. platforms).
+type Variables map[string]interface{}
 
 // Merge two Variables.
 func (v Variables) Merge(other Variables) Variables {
@@ -16,3 +19,66 @@ func (v Variables) Merge(other Variables) Variables {
 
 	return v
 }
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+//
+// Scalar values are kept as strings (as written), so that e.g. version `1.20` is not converted to float.
+func (v *Variables) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var values map[string]variableValue
+
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+
+	*v = make(Variables, len(values))
+
+	for key, value := range values {
+		(*v)[key] = value.value
+	}
+
+	return nil
+}
+
+type variableValue struct {
+	value interface{}
+}
+
+func (v *variableValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var scalar string
+
+	if err := unmarshal(&scalar); err == nil {
+		v.value = scalar
+
+		return nil
+	}
+
+	var list []variableValue
+
+	if err := unmarshal(&list); err == nil {
+		values := make([]interface{}, len(list))
+
+		for i := range list {
+			values[i] = list[i].value
+		}
+
+		v.value = values
+
+		return nil
+	}
+
+	var mapping map[string]variableValue
+
+	if err := unmarshal(&mapping); err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{}, len(mapping))
+
+	for key, value := range mapping {
+		values[key] = value.value
+	}
+
+	v.value = values
+
+	return nil
+}
@@ -67,7 +67,12 @@ func convertSteps(old []*v1alpha1.Step) []v1alpha2.Step {
 		}
 
 		for _, src := range step.Sources {
-			newStep.Sources = append(newStep.Sources, v1alpha2.Source(*src))
+			newStep.Sources = append(newStep.Sources, v1alpha2.Source{
+				URL:         src.URL,
+				Destination: src.Destination,
+				SHA256:      src.SHA256,
+				SHA512:      src.SHA512,
+			})
 		}
 
 		new = append(new, newStep)
@@ -78,7 +83,10 @@ func convertSteps(old []*v1alpha1.Step) []v1alpha2.Step {
 func convertFinalize(old []*v1alpha1.Finalize) []v1alpha2.Finalize {
 	new := []v1alpha2.Finalize{}
 	for _, f := range old {
-		new = append(new, v1alpha2.Finalize(*f))
+		new = append(new, v1alpha2.Finalize{
+			From: f.From,
+			To:   f.To,
+		})
 	}
 
 	return new
@@ -96,7 +104,7 @@ func FromV1Alpha1(oldPkg *v1alpha1.Pkg, stageNames []string) *v1alpha2.Pkg {
 		Dependencies: convertDeps(stageNames, oldPkg.Dependencies),
 		Steps:        convertSteps(oldPkg.Steps),
 		Finalize:     convertFinalize(oldPkg.Finalize),
-		Variant:      v1alpha2.Variant(oldPkg.Variant),
+		Variant:      v1alpha2.Variant(oldPkg.Variant.String()),
 		Shell:        v1alpha2.Shell(oldPkg.Shell),
 	}
 }
@@ -14,8 +14,9 @@ import (
 // BuildkitRunner runs bldr via buildctl/buildkit.
 type BuildkitRunner struct {
 	CommandRunner
-	Target   string
-	Platform string
+	Target        string
+	Platform      string
+	BuildPlatform string
 }
 
 // Run implements Run interface.
@@ -37,6 +38,10 @@ func (runner BuildkitRunner) Run(t *testing.T) {
 		args = append(args, "--opt", "platform="+runner.Platform)
 	}
 
+	if runner.BuildPlatform != "" {
+		args = append(args, "--opt", "build-platform="+runner.BuildPlatform)
+	}
+
 	cmd := exec.Command("buildctl", args...)
 
 	runner.run(t, cmd, "buildkit")
@@ -16,8 +16,9 @@ import (
 // LLBRunner runs bldr via bldr llb | buildctl.
 type LLBRunner struct {
 	CommandRunner
-	Target   string
-	Platform string
+	Target        string
+	Platform      string
+	BuildPlatform string
 }
 
 // Run implements Run interface.
@@ -33,7 +34,12 @@ func (runner LLBRunner) Run(t *testing.T) {
 
 	platformArgs := ""
 	if runner.Platform != "" {
-		platformArgs = fmt.Sprintf("--build-platform=%s --target-platform=%s", shellescape.Quote(runner.Platform), shellescape.Quote(runner.Platform))
+		buildPlatform := runner.Platform
+		if runner.BuildPlatform != "" {
+			buildPlatform = runner.BuildPlatform
+		}
+
+		platformArgs = fmt.Sprintf("--build-platform=%s --target-platform=%s", shellescape.Quote(buildPlatform), shellescape.Quote(runner.Platform))
 	}
 
 	cmd := exec.Command("/bin/sh", "-c",
@@ -22,6 +22,9 @@ type RunManifest struct {
 	Platform string `yaml:"platform"`
 	Target   string `yaml:"target"`
 	Expect   string `yaml:"expect"`
+
+	// BuildPlatform overrides build platform (defaults to Platform).
+	BuildPlatform string `yaml:"build-platform"`
 }
 
 // NewTestManifest loads TestManifest from test.yaml file.
@@ -56,16 +56,18 @@ func getRunner(manifest RunManifest) (Run, error) {
 			CommandRunner: CommandRunner{
 				Expect: manifest.Expect,
 			},
-			Target:   manifest.Target,
-			Platform: manifest.Platform,
+			Target:        manifest.Target,
+			Platform:      manifest.Platform,
+			BuildPlatform: manifest.BuildPlatform,
 		}, nil
 	case "llb":
 		return LLBRunner{
 			CommandRunner: CommandRunner{
 				Expect: manifest.Expect,
 			},
-			Target:   manifest.Target,
-			Platform: manifest.Platform,
+			Target:        manifest.Target,
+			Platform:      manifest.Platform,
+			BuildPlatform: manifest.BuildPlatform,
 		}, nil
 	case "validate":
 		return ValidateRunner{